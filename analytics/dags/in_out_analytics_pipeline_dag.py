@@ -97,6 +97,33 @@ with DAG(
         }
     )
 
+    #Cloud Run Job
+    # Store the closing inventory snapshot of the previous month in Firestore
+    # This job can be overriden with a $YEAR and $MONTH env vars **To close a month again**
+    trigger_inventory_close_job = CloudRunExecuteJobOperator(
+        task_id='trigger_inventory_close_job',
+        region='us-central1',
+        project_id='b-materials',
+        job_name='in-out-inventory-close',
+        overrides={
+            "container_overrides": [
+                {
+                    "env": [
+                        {
+                            "name": "YEAR",
+                            "value": "{{ ti.xcom_pull(task_ids='get_target_dag_date', key='prev_year') }}"
+                        },
+                        {
+                            "name": "MONTH",
+                            "value": "{{ ti.xcom_pull(task_ids='get_target_dag_date', key='prev_month') }}"
+                        }
+                    ]
+                }
+            ]
+        }
+    )
+
     end = EmptyOperator(task_id='end')
 
 start >> get_target_dag_date >> trigger_el_job >> trigger_dbt_job_silver >> end
+get_target_dag_date >> trigger_inventory_close_job >> end
@@ -9,6 +9,22 @@ import sys
 logging.basicConfig(level=logging.INFO, format='%(asctime)s - %(levelname)s - %(message)s', stream=sys.stdout)
 logger = logging.getLogger('in-out-analytics')
 
+# Keys of the line items in Firestore and their names in BigQuery
+LINE_ITEM_KEYS = {
+    "SKU": "sku",
+    "Descripcion": "descripcion",
+    "LoteSerie": "lote_serie",
+    "Cantidad": "cantidad",
+    "UnidadMedida": "unidad_medida",
+    "Condicion": "condicion",
+}
+
+def normalize_line_items(items) -> list:
+    """Rename line item keys for BigQuery, documents without line items get an empty list."""
+    if not isinstance(items, list):
+        return []
+    return [{LINE_ITEM_KEYS[k]: v for k, v in item.items() if k in LINE_ITEM_KEYS} for item in items]
+
 class FetchDocuments():
     def __init__(self, project_id: str):
         self.project_id = project_id
@@ -49,14 +65,22 @@ class FetchDocuments():
 
     def prepare_entradas_data(self, data: pd.DataFrame) -> pd.DataFrame:
         try:
+            # Only voided documents carry DeletedAt
+            if "DeletedAt" not in data.columns:
+                data["DeletedAt"] = None
+            # Line items are optional and missing on older documents
+            if "LineItems" not in data.columns:
+                data["LineItems"] = None
+            data["LineItems"] = data["LineItems"].apply(normalize_line_items)
+
             data = data[[
                 "id", "BodegaRecepcion", "Cantidad", "Cliente", "FechaRecepcion", "FechaAjusteASN",
-                "TipoDelivery", "PersonaRecepcion", "ProveedorRecepcion", "Type"
+                "TipoDelivery", "PersonaRecepcion", "ProveedorRecepcion", "Type", "DeletedAt", "LineItems"
             ]]
 
             expected_columns = {"id", "BodegaRecepcion", "Cantidad", "Cliente", 
                                 "FechaRecepcion", "FechaAjusteASN", "TipoDelivery", "PersonaRecepcion", 
-                                "ProveedorRecepcion", "Type"}
+                                "ProveedorRecepcion", "Type", "DeletedAt", "LineItems"}
             missing = expected_columns - set(data.columns)
             if missing:
                 logger.error(f"Missing expected columns: {missing}")
@@ -72,7 +96,9 @@ class FetchDocuments():
                 "TipoDelivery": "tipo_delivery",
                 "PersonaRecepcion": "operador",
                 "ProveedorRecepcion": "proveedor",
-                "Type": "tipo"
+                "Type": "tipo",
+                "DeletedAt": "fecha_eliminacion",
+                "LineItems": "line_items"
             }, inplace=True)
             
             return data
@@ -82,13 +108,24 @@ class FetchDocuments():
         
     def prepare_salidas_data(self, data: pd.DataFrame) -> pd.DataFrame:
         try:
+            # Only voided documents carry DeletedAt
+            if "DeletedAt" not in data.columns:
+                data["DeletedAt"] = None
+            # Quantities come from the line items, missing on older documents
+            if "Cantidad" not in data.columns:
+                data["Cantidad"] = 0
+            data["Cantidad"] = data["Cantidad"].fillna(0).astype(int)
+            if "LineItems" not in data.columns:
+                data["LineItems"] = None
+            data["LineItems"] = data["LineItems"].apply(normalize_line_items)
+
             data = data[[
-                "id", "BodegaSalida", "Cliente", "FechaSalida", "PersonaEntrega", "ProveedorSalida",
-                "Type"
+                "id", "BodegaSalida", "Cantidad", "Cliente", "FechaSalida", "PersonaEntrega", "ProveedorSalida",
+                "Type", "DeletedAt", "LineItems"
             ]]
 
-            expected_columns = {"id", "BodegaSalida", "Cliente", "FechaSalida",
-                                "PersonaEntrega", "ProveedorSalida", "Type"}
+            expected_columns = {"id", "BodegaSalida", "Cantidad", "Cliente", "FechaSalida",
+                                "PersonaEntrega", "ProveedorSalida", "Type", "DeletedAt", "LineItems"}
             missing = expected_columns - set(data.columns)
             if missing:
                 logger.error(f"Missing expected columns: {missing}")
@@ -97,14 +134,16 @@ class FetchDocuments():
             data.rename(columns={
                 "id": "landing_movement_id",
                 "BodegaSalida": "bodega",
+                "Cantidad": "cantidad",
                 "Cliente": "cliente",
                 "FechaSalida": "fecha_movimiento",
                 "PersonaEntrega": "operador",
                 "ProveedorSalida": "proveedor",
-                "Type": "tipo"
+                "Type": "tipo",
+                "DeletedAt": "fecha_eliminacion",
+                "LineItems": "line_items"
             }, inplace=True)
 
-            data["cantidad"] = 0 #Not tracked
             data["fecha_ajuste_asn"] = None #Does not apply
             data["tipo_delivery"] = None #Does not apply
 
@@ -57,8 +57,10 @@ WITH new_in_out_data AS(
         UPPER(COALESCE(tipo, 'UNKNOWN')) AS tipo 
     FROM 
         `b-materials.in_out_bronze.landing_in_out_movements`
+    -- Voided movements (soft deleted in the API) are excluded
+    WHERE fecha_eliminacion IS NULL
     {% if backfill_month is not none %}
-    WHERE TIMESTAMP_TRUNC(fecha_movimiento, MONTH) = TIMESTAMP('{{ backfill_month }}')
+    AND TIMESTAMP_TRUNC(fecha_movimiento, MONTH) = TIMESTAMP('{{ backfill_month }}')
     {% endif %}
 )
 
@@ -29,6 +29,15 @@ class LoadToBigQuery:
                 bigquery.SchemaField("operador", bigquery.enums.SqlTypeNames.STRING, mode="NULLABLE"),
                 bigquery.SchemaField("proveedor", bigquery.enums.SqlTypeNames.STRING, mode="NULLABLE"),
                 bigquery.SchemaField("tipo", bigquery.enums.SqlTypeNames.STRING, mode="NULLABLE"),
+                bigquery.SchemaField("fecha_eliminacion", bigquery.enums.SqlTypeNames.TIMESTAMP, mode="NULLABLE"),
+                bigquery.SchemaField("line_items", bigquery.enums.SqlTypeNames.RECORD, mode="REPEATED", fields=[
+                    bigquery.SchemaField("sku", bigquery.enums.SqlTypeNames.STRING, mode="NULLABLE"),
+                    bigquery.SchemaField("descripcion", bigquery.enums.SqlTypeNames.STRING, mode="NULLABLE"),
+                    bigquery.SchemaField("lote_serie", bigquery.enums.SqlTypeNames.STRING, mode="NULLABLE"),
+                    bigquery.SchemaField("cantidad", bigquery.enums.SqlTypeNames.INTEGER, mode="NULLABLE"),
+                    bigquery.SchemaField("unidad_medida", bigquery.enums.SqlTypeNames.STRING, mode="NULLABLE"),
+                    bigquery.SchemaField("condicion", bigquery.enums.SqlTypeNames.STRING, mode="NULLABLE"),
+                ]),
             ]
         )
 
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"github.com/clopezbyte/app-entradas-salidas/audit"
+	"github.com/clopezbyte/app-entradas-salidas/handlers"
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/clopezbyte/app-entradas-salidas/utils"
+	"google.golang.org/api/option"
+)
+
+// App owns the long lived clients of the API. They are created once at startup
+// and shared by every request until Close is called on shutdown.
+type App struct {
+	Store    *store.Store
+	Storage  *storage.Client
+	Verifier utils.TokenVerifier
+}
+
+// New creates the store, GCS and token verifier clients.
+// STORE_BACKEND=memory uses the in-memory store and tolerates missing GCP credentials.
+// AUTH_VERIFIER selects how ID tokens are verified: firebase (default), jwt or static.
+// TIPOS_DELIVERY, a comma-separated list, replaces the accepted entrada delivery types.
+func New(ctx context.Context) (*App, error) {
+	memory := os.Getenv("STORE_BACKEND") == "memory"
+	a := &App{}
+
+	if raw := os.Getenv("TIPOS_DELIVERY"); raw != "" {
+		var tipos []string
+		for _, tipo := range strings.Split(raw, ",") {
+			if tipo = strings.TrimSpace(tipo); tipo != "" {
+				tipos = append(tipos, tipo)
+			}
+		}
+		models.TiposDelivery = tipos
+	}
+
+	if memory {
+		log.Println("Using in-memory store")
+		a.Store = store.NewMemory()
+	} else {
+		fsClient, err := firestore.NewClientWithDatabase(ctx, store.ProjectID, store.DatabaseID)
+		if err != nil {
+			return nil, err
+		}
+		a.Store = store.NewFirestore(fsClient)
+	}
+
+	storageClient, err := storage.NewClient(ctx)
+	if err != nil && memory {
+		log.Printf("GCS credentials not found, using anonymous client: %v", err)
+		storageClient, err = storage.NewClient(ctx, option.WithoutAuthentication())
+	}
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	a.Storage = storageClient
+
+	verifier, err := newVerifier(ctx, memory)
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	a.Verifier = verifier
+
+	return a, nil
+}
+
+// Builds the TokenVerifier selected by AUTH_VERIFIER
+func newVerifier(ctx context.Context, memory bool) (utils.TokenVerifier, error) {
+	switch os.Getenv("AUTH_VERIFIER") {
+	case "jwt":
+		log.Println("Verifying tokens with the local JWT key")
+		return utils.NewJWTVerifierFromEnv()
+	case "static":
+		log.Println("Verifying tokens against AUTH_STATIC_TOKENS")
+		return utils.NewStaticVerifierFromEnv()
+	case "", "firebase":
+		authClient, err := utils.InitializeFirebase(ctx)
+		if err != nil {
+			if !memory {
+				return nil, err
+			}
+			log.Printf("Firebase not available, authenticated routes will reject requests: %v", err)
+		}
+		return &utils.FirebaseVerifier{Client: authClient}, nil
+	}
+	return nil, fmt.Errorf("unknown AUTH_VERIFIER %q", os.Getenv("AUTH_VERIFIER"))
+}
+
+// Handler returns the HTTP handlers bound to the App clients
+func (a *App) Handler() *handlers.Handler {
+	return &handlers.Handler{
+		Store:    a.Store,
+		Storage:  a.Storage,
+		Verifier: a.Verifier,
+		Audit:    audit.New(a.Store.Audit),
+	}
+}
+
+// Close releases every client held by the App
+func (a *App) Close() error {
+	var errs []error
+	if a.Store != nil {
+		errs = append(errs, a.Store.Close())
+	}
+	if a.Storage != nil {
+		errs = append(errs, a.Storage.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+)
+
+// Logger records every mutation of entradas, salidas and customers in the
+// append-only audit log
+type Logger struct {
+	repo store.AuditRepository
+}
+
+func New(repo store.AuditRepository) *Logger {
+	return &Logger{repo: repo}
+}
+
+// Record appends an entry with the fields changed on a document. before is nil
+// for creates and after is nil for deletes. Failures are logged and never fail
+// the request that already committed the mutation.
+func (l *Logger) Record(ctx context.Context, actor, action, collection, documentID string, before, after interface{}) {
+	changes, err := Diff(before, after)
+	if err != nil {
+		log.Printf("Audit diff failed for %s/%s: %v", collection, documentID, err)
+		return
+	}
+
+	entry := models.AuditEntry{
+		Actor:      actor,
+		Action:     action,
+		Collection: collection,
+		DocumentID: documentID,
+		Changes:    changes,
+		Timestamp:  time.Now().UTC(),
+	}
+	if err := l.repo.Append(ctx, entry); err != nil {
+		log.Printf("Failed to write audit entry for %s/%s: %v", collection, documentID, err)
+	}
+}
+
+// Diff returns the fields whose value differs between two documents, keyed by
+// their JSON name. Either side may be nil.
+func Diff(before, after interface{}) (map[string]models.FieldChange, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]models.FieldChange{}
+	for field, value := range a {
+		if old, ok := b[field]; !ok || !reflect.DeepEqual(old, value) {
+			changes[field] = models.FieldChange{Before: b[field], After: value}
+		}
+	}
+	for field, old := range b {
+		if _, ok := a[field]; !ok {
+			changes[field] = models.FieldChange{Before: old}
+		}
+	}
+	return changes, nil
+}
+
+// Flattens a document into its JSON fields
+func toMap(doc interface{}) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if doc == nil {
+		return m, nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
@@ -0,0 +1,53 @@
+// Command devtoken mints an HS256 ID token accepted by the JWT verifier
+// (AUTH_VERIFIER=jwt), for local development and integration tests.
+//
+//	AUTH_JWT_SECRET=dev-secret go run ./cmd/devtoken -sub u1 -role operator
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func main() {
+	sub := flag.String("sub", "dev-user", "user ID (sub claim)")
+	email := flag.String("email", "dev@example.com", "email claim")
+	role := flag.String("role", "operator", "role claim")
+	cliente := flag.String("cliente", "", "cliente claim, for customer-readonly users")
+	ttl := flag.Duration("ttl", 12*time.Hour, "token lifetime")
+	flag.Parse()
+
+	secret := os.Getenv("AUTH_JWT_SECRET")
+	if secret == "" {
+		log.Fatal("AUTH_JWT_SECRET not set in environment")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   *sub,
+		"email": *email,
+		"role":  *role,
+		"iat":   now.Unix(),
+		"exp":   now.Add(*ttl).Unix(),
+	}
+	if *cliente != "" {
+		claims["cliente"] = *cliente
+	}
+	if iss := os.Getenv("AUTH_JWT_ISSUER"); iss != "" {
+		claims["iss"] = iss
+	}
+	if aud := os.Getenv("AUTH_JWT_AUDIENCE"); aud != "" {
+		claims["aud"] = aud
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		log.Fatalf("Error signing token: %v", err)
+	}
+	fmt.Println(token)
+}
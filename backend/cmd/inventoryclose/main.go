@@ -0,0 +1,61 @@
+// Command inventoryclose stores the closing inventory snapshot of a month.
+// It runs as the in-out-inventory-close Cloud Run job on the first day of each
+// month and closes the previous one; YEAR and MONTH override the month, e.g.
+// to close it again after late corrections.
+//
+//	YEAR=2025 MONTH=6 go run ./cmd/inventoryclose
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/clopezbyte/app-entradas-salidas/inventory"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+)
+
+// Recorded as the author of the snapshots written by the job
+const actor = "inventory-close-job"
+
+func main() {
+	ctx := context.Background()
+
+	// Previous month by default
+	now := time.Now().UTC()
+	target := now.AddDate(0, 0, -now.Day())
+	year, month := target.Year(), target.Month()
+	if v := os.Getenv("YEAR"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("Invalid YEAR %q", v)
+		}
+		year = y
+	}
+	if v := os.Getenv("MONTH"); v != "" {
+		m, err := strconv.Atoi(v)
+		if err != nil || m < 1 || m > 12 {
+			log.Fatalf("Invalid MONTH %q", v)
+		}
+		month = time.Month(m)
+	}
+	if period, asOf := inventory.Period(year, month); asOf.After(now) {
+		log.Fatalf("%s has not ended yet", period)
+	}
+
+	client, err := firestore.NewClientWithDatabase(ctx, store.ProjectID, store.DatabaseID)
+	if err != nil {
+		log.Fatalf("Error creating Firestore client: %v", err)
+	}
+	st := store.NewFirestore(client)
+
+	snapshot, err := inventory.Close(ctx, st, year, month, actor)
+	st.Close()
+	if err != nil {
+		log.Fatalf("Error closing inventory: %v", err)
+	}
+	log.Printf("Closed %s with %d balances", snapshot.Period, len(snapshot.Balances))
+}
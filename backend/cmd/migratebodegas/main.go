@@ -0,0 +1,209 @@
+// Command migratebodegas rewrites the free-text BodegaRecepcion and
+// BodegaSalida values of existing movements to the codes of the bodegas
+// collection. Spellings are matched against the code and name of every bodega
+// ignoring case and extra spaces; a CSV with "spelling,code" rows maps the
+// rest. Without -apply it only prints the plan.
+//
+//	go run ./cmd/migratebodegas -mapping bodegas.csv
+//	go run ./cmd/migratebodegas -mapping bodegas.csv -apply
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/clopezbyte/app-entradas-salidas/audit"
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+)
+
+// Recorded as the author of the corrections
+const actor = "bodegas-migration"
+
+// A movement whose bodega is not a canonical code
+type movement struct {
+	collection string
+	id         string
+	spelling   string
+	deleted    bool
+}
+
+func main() {
+	mappingPath := flag.String("mapping", "", "CSV file of spelling,code rows for the spellings that do not match a bodega")
+	apply := flag.Bool("apply", false, "write the corrections, otherwise only print the plan")
+	flag.Parse()
+
+	ctx := context.Background()
+	client, err := firestore.NewClientWithDatabase(ctx, store.ProjectID, store.DatabaseID)
+	if err != nil {
+		log.Fatalf("Error creating Firestore client: %v", err)
+	}
+	st := store.NewFirestore(client)
+	err = run(ctx, st, *mappingPath, *apply)
+	st.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, st *store.Store, mappingPath string, apply bool) error {
+	bodegas, err := st.Bodegas.List(ctx, true)
+	if err != nil {
+		return fmt.Errorf("listing bodegas: %w", err)
+	}
+	if len(bodegas) == 0 {
+		return errors.New("the bodegas collection is empty, register the bodegas first")
+	}
+	codes := map[string]bool{}
+	lookup := map[string]string{}
+	for _, bodega := range bodegas {
+		codes[bodega.Code] = true
+		lookup[models.FoldSpelling(bodega.Code)] = bodega.Code
+		lookup[models.FoldSpelling(bodega.Name)] = bodega.Code
+	}
+	if mappingPath != "" {
+		if err := readMapping(mappingPath, codes, lookup); err != nil {
+			return err
+		}
+	}
+
+	movements, err := collect(ctx, st, codes)
+	if err != nil {
+		return err
+	}
+
+	// Summary per spelling
+	mapped := map[string]int{}
+	unmapped := map[string]int{}
+	for _, m := range movements {
+		if _, ok := lookup[models.FoldSpelling(m.spelling)]; ok {
+			mapped[m.spelling]++
+		} else {
+			unmapped[m.spelling]++
+		}
+	}
+	for _, spelling := range sortedKeys(mapped) {
+		fmt.Printf("%q -> %s (%d movements)\n", spelling, lookup[models.FoldSpelling(spelling)], mapped[spelling])
+	}
+	for _, spelling := range sortedKeys(unmapped) {
+		fmt.Printf("%q unmapped (%d movements), add it to the mapping file\n", spelling, unmapped[spelling])
+	}
+	if !apply {
+		fmt.Println("Dry run, rerun with -apply to write the corrections")
+		return nil
+	}
+
+	logger := audit.New(st.Audit)
+	var updated, skipped int
+	for _, m := range movements {
+		code, ok := lookup[models.FoldSpelling(m.spelling)]
+		if !ok {
+			continue
+		}
+		// Voided movements cannot be corrected, they stay out of listings and the inventory anyway
+		if m.deleted {
+			skipped++
+			continue
+		}
+		if err := correct(ctx, st, logger, m, code); err != nil {
+			return fmt.Errorf("updating %s/%s: %w", m.collection, m.id, err)
+		}
+		updated++
+	}
+	fmt.Printf("Updated %d movements, skipped %d voided ones\n", updated, skipped)
+	return nil
+}
+
+// Reads the spelling,code rows of the mapping file, a header row is optional
+func readMapping(path string, codes map[string]bool, lookup map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = 2
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "spelling") {
+			continue
+		}
+		code := models.NormalizeBodegaCode(record[1])
+		if !codes[code] {
+			return fmt.Errorf("%s line %d: unknown bodega code %q", path, line, record[1])
+		}
+		lookup[models.FoldSpelling(record[0])] = code
+	}
+}
+
+// Lists the movements, voided ones included, whose bodega is not a canonical code
+func collect(ctx context.Context, st *store.Store, codes map[string]bool) ([]movement, error) {
+	var movements []movement
+	err := st.Entradas.Each(ctx, store.EntradaFilter{IncludeDeleted: true}, func(e models.EntradasDataWithID) error {
+		if !codes[e.BodegaRecepcion] {
+			movements = append(movements, movement{store.EntradasCollection, e.ID, e.BodegaRecepcion, e.Deleted()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing entradas: %w", err)
+	}
+	err = st.Salidas.Each(ctx, store.SalidaFilter{IncludeDeleted: true}, func(s models.SalidasDataWithID) error {
+		if !codes[s.BodegaSalida] {
+			movements = append(movements, movement{store.SalidasCollection, s.ID, s.BodegaSalida, s.Deleted()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing salidas: %w", err)
+	}
+	return movements, nil
+}
+
+// Sets the canonical code on a movement through the repositories, so that
+// the inventory balances follow and the change is audited. Balances move
+// between bodegas one movement at a time, so they may dip below zero midway.
+func correct(ctx context.Context, st *store.Store, logger *audit.Logger, m movement, code string) error {
+	ctx = store.WithStockOverride(ctx, actor)
+	now := time.Now().UTC()
+	if m.collection == store.EntradasCollection {
+		before, after, err := st.Entradas.Update(ctx, m.id, models.EntradasPatch{BodegaRecepcion: &code, UpdatedBy: actor, UpdatedAt: now})
+		if err != nil {
+			return err
+		}
+		logger.Record(ctx, actor, models.AuditUpdate, m.collection, m.id, before, after)
+		return nil
+	}
+	before, after, err := st.Salidas.Update(ctx, m.id, models.SalidasPatch{BodegaSalida: &code, UpdatedBy: actor, UpdatedAt: now})
+	if err != nil {
+		return err
+	}
+	logger.Record(ctx, actor, models.AuditUpdate, m.collection, m.id, before, after)
+	return nil
+}
+
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
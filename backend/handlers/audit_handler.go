@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/store"
+)
+
+const (
+	defaultAuditLimit = 200
+	maxAuditLimit     = 1000
+)
+
+// HandleAuditQuery lists audit log entries, newest first. Optional filters:
+// collection, document_id, user, from and to (RFC3339) and limit.
+func (h *Handler) HandleAuditQuery(w http.ResponseWriter, r *http.Request) {
+	// Use the request's context for proper cancellation
+	ctx := r.Context()
+
+	filter := store.AuditFilter{
+		Collection: r.FormValue("collection"),
+		DocumentID: r.FormValue("document_id"),
+		Actor:      r.FormValue("user"),
+		Limit:      defaultAuditLimit,
+	}
+
+	var err error
+	if fromRaw := r.FormValue("from"); fromRaw != "" {
+		filter.From, err = time.Parse(time.RFC3339, fromRaw)
+		if err != nil {
+			http.Error(w, "Invalid from format", http.StatusBadRequest)
+			return
+		}
+	}
+	if toRaw := r.FormValue("to"); toRaw != "" {
+		filter.To, err = time.Parse(time.RFC3339, toRaw)
+		if err != nil {
+			http.Error(w, "Invalid to format", http.StatusBadRequest)
+			return
+		}
+	}
+	if limitRaw := r.FormValue("limit"); limitRaw != "" {
+		filter.Limit, err = strconv.Atoi(limitRaw)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxAuditLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.Store.Audit.Query(ctx, filter)
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
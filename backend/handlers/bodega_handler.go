@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/gorilla/mux"
+)
+
+// errInactiveBodega is returned when a movement names a deactivated bodega
+var errInactiveBodega = errors.New("bodega is inactive")
+
+// Returns the canonical code of the active bodega a movement names. Codes
+// are matched regardless of case and surrounding spaces.
+func (h *Handler) activeBodega(ctx context.Context, raw string) (string, error) {
+	code := models.NormalizeBodegaCode(raw)
+	if code == "" {
+		return "", store.ErrNotFound
+	}
+	bodega, err := h.Store.Bodegas.Get(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	if !bodega.Active {
+		return "", errInactiveBodega
+	}
+	return bodega.Code, nil
+}
+
+// Resolves a bodega to its canonical code. The message is empty when it is a
+// registered, active bodega.
+func (h *Handler) resolveBodega(ctx context.Context, raw string) (string, string, error) {
+	code, err := h.activeBodega(ctx, raw)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return "", fmt.Sprintf("unknown bodega %q, it must be the code of a registered bodega", raw), nil
+	case errors.Is(err, errInactiveBodega):
+		return "", fmt.Sprintf("bodega %q is inactive", raw), nil
+	case err != nil:
+		return "", "", err
+	}
+	return code, "", nil
+}
+
+// HandleListBodegas returns the active bodegas ordered by code, every one with include_inactive=true
+func (h *Handler) HandleListBodegas(w http.ResponseWriter, r *http.Request) {
+	includeInactive, _ := strconv.ParseBool(r.FormValue("include_inactive"))
+
+	bodegas, err := h.Store.Bodegas.List(r.Context(), includeInactive)
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	if bodegas == nil {
+		bodegas = []models.Bodega{}
+	}
+
+	writeJSON(w, http.StatusOK, bodegas)
+}
+
+// HandleGetBodega returns one bodega
+func (h *Handler) HandleGetBodega(w http.ResponseWriter, r *http.Request) {
+	code := models.NormalizeBodegaCode(mux.Vars(r)["code"])
+
+	bodega, err := h.Store.Bodegas.Get(r.Context(), code)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Bodega not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, bodega)
+}
+
+// HandleCreateBodega registers a bodega from the JSON body, active unless
+// "active": false is sent
+func (h *Handler) HandleCreateBodega(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Code     string `json:"code"`
+		Name     string `json:"name"`
+		Address  string `json:"address"`
+		Timezone string `json:"timezone"`
+		Active   *bool  `json:"active"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	bodega := models.Bodega{
+		Code:      models.NormalizeBodegaCode(payload.Code),
+		Name:      payload.Name,
+		Address:   payload.Address,
+		Timezone:  payload.Timezone,
+		Active:    payload.Active == nil || *payload.Active,
+		CreatedBy: actorUID(r),
+		CreatedAt: now,
+		UpdatedBy: actorUID(r),
+		UpdatedAt: now,
+	}
+	if err := bodega.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.Store.Bodegas.Create(ctx, bodega); err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			http.Error(w, "Bodega already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Error saving bodega to Firestore: %v", err)
+		http.Error(w, "Error saving bodega to Firestore", http.StatusInternalServerError)
+		return
+	}
+	h.Audit.Record(ctx, bodega.CreatedBy, models.AuditCreate, store.BodegasCollection, bodega.Code, nil, bodega)
+
+	writeJSON(w, http.StatusCreated, bodega)
+}
+
+// HandlePatchBodega changes the fields sent in the JSON body of a bodega
+func (h *Handler) HandlePatchBodega(w http.ResponseWriter, r *http.Request) {
+	code := models.NormalizeBodegaCode(mux.Vars(r)["code"])
+
+	var patch models.BodegaPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	patch.UpdatedBy = actorUID(r)
+	patch.UpdatedAt = time.Now().UTC()
+
+	h.updateBodega(w, r, code, patch)
+}
+
+// HandleDeleteBodega deactivates a bodega. It is kept since movements reference
+// its code, but new movements can no longer name it.
+func (h *Handler) HandleDeleteBodega(w http.ResponseWriter, r *http.Request) {
+	code := models.NormalizeBodegaCode(mux.Vars(r)["code"])
+
+	active := false
+	h.updateBodega(w, r, code, models.BodegaPatch{
+		Active:    &active,
+		UpdatedBy: actorUID(r),
+		UpdatedAt: time.Now().UTC(),
+	})
+}
+
+// Validates and applies a patch to a bodega, answering the bodega after it
+func (h *Handler) updateBodega(w http.ResponseWriter, r *http.Request, code string, patch models.BodegaPatch) {
+	ctx := r.Context()
+	current, err := h.Store.Bodegas.Get(ctx, code)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Bodega not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	if err := patch.Apply(current).Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	before, after, err := h.Store.Bodegas.Update(ctx, code, patch)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Bodega not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update bodega %s: %v", code, err)
+		http.Error(w, "Failed to update bodega", http.StatusInternalServerError)
+		return
+	}
+	h.Audit.Record(ctx, patch.UpdatedBy, models.AuditUpdate, store.BodegasCollection, code, before, after)
+
+	writeJSON(w, http.StatusOK, after)
+}
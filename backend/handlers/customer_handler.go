@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/gorilla/mux"
+)
+
+// Every customer, inactive ones included. Writes through this API reset the cache.
+var (
+	customersCache      []models.Customer
+	customersCacheTime  time.Time
+	customersCacheMutex sync.Mutex
+)
+
+// Returns the cached customers, reading them again when the cache expired
+func (h *Handler) cachedCustomers(ctx context.Context) ([]models.Customer, error) {
+	customersCacheMutex.Lock()
+	defer customersCacheMutex.Unlock()
+
+	// Serve from cache if not expired
+	if time.Since(customersCacheTime) < cacheDuration && customersCache != nil {
+		return customersCache, nil
+	}
+	customers, err := h.Store.Customers.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if customers == nil {
+		customers = []models.Customer{}
+	}
+	customersCache = customers
+	customersCacheTime = time.Now()
+	return customers, nil
+}
+
+// Drops the cached customers after a write
+func invalidateCustomers() {
+	customersCacheMutex.Lock()
+	defer customersCacheMutex.Unlock()
+	customersCache = nil
+}
+
+// HandleListCustomers returns the active customers ordered by ID, every one
+// with include_inactive=true. Served from the same cache as /get-customers.
+func (h *Handler) HandleListCustomers(w http.ResponseWriter, r *http.Request) {
+	includeInactive, _ := strconv.ParseBool(r.FormValue("include_inactive"))
+
+	customers, err := h.cachedCustomers(r.Context())
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	result := []models.Customer{}
+	for _, customer := range customers {
+		if customer.Active() || includeInactive {
+			result = append(result, customer)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandleGetCustomer returns one customer
+func (h *Handler) HandleGetCustomer(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	customer, err := h.Store.Customers.Get(r.Context(), ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, customer)
+}
+
+// HandlePutCustomer replaces every field of an existing customer with the JSON
+// body. A missing status means active.
+func (h *Handler) HandlePutCustomer(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	var payload struct {
+		Code     string                   `json:"code"`
+		Email    string                   `json:"email"`
+		RepName  string                   `json:"rep_name"`
+		Status   string                   `json:"status"`
+		Contacts []models.CustomerContact `json:"contacts"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	customer := models.Customer{
+		ID:       ID,
+		Code:     payload.Code,
+		Email:    payload.Email,
+		RepName:  payload.RepName,
+		Status:   payload.Status,
+		Contacts: payload.Contacts,
+	}
+	if customer.Status == "" {
+		customer.Status = models.CustomerActive
+	}
+	if err := customer.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	before, err := h.Store.Customers.Replace(ctx, ID, customer)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to replace customer %s: %v", ID, err)
+		http.Error(w, "Failed to replace customer", http.StatusInternalServerError)
+		return
+	}
+	invalidateCustomers()
+	h.Audit.Record(ctx, actorUID(r), models.AuditUpdate, store.CustomersCollection, ID, before, customer)
+
+	writeJSON(w, http.StatusOK, customer)
+}
+
+// HandlePatchCustomer changes the fields sent in the JSON body of a customer
+func (h *Handler) HandlePatchCustomer(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	var patch models.CustomerPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.updateCustomer(w, r, ID, patch)
+}
+
+// HandleDeactivateCustomer marks a customer inactive. It is kept since
+// movements reference it, but it leaves the customer dropdowns.
+func (h *Handler) HandleDeactivateCustomer(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	status := models.CustomerInactive
+	h.updateCustomer(w, r, ID, models.CustomerPatch{Status: &status})
+}
+
+// Validates and applies a patch to a customer, answering the customer after it
+func (h *Handler) updateCustomer(w http.ResponseWriter, r *http.Request, ID string, patch models.CustomerPatch) {
+	ctx := r.Context()
+	current, err := h.Store.Customers.Get(ctx, ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	if err := patch.Apply(current).Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	before, after, err := h.Store.Customers.Update(ctx, ID, patch)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update customer %s: %v", ID, err)
+		http.Error(w, "Failed to update customer", http.StatusInternalServerError)
+		return
+	}
+	invalidateCustomers()
+	h.Audit.Record(ctx, actorUID(r), models.AuditUpdate, store.CustomersCollection, ID, before, after)
+
+	writeJSON(w, http.StatusOK, after)
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,36 +11,15 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/gorilla/mux"
 
-	"cloud.google.com/go/firestore"
-	"cloud.google.com/go/storage"
 	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/reports"
+	"github.com/clopezbyte/app-entradas-salidas/store"
 	"github.com/clopezbyte/app-entradas-salidas/utils"
 )
 
-func HandleEntradasSubmit(w http.ResponseWriter, r *http.Request) {
-	// Get token from header using utils
-	authHeader := r.Header.Get("Authorization")
-	idToken, err := utils.GetTokenFromHeader(authHeader)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		log.Printf("Invalid header token: %v", err)
-
-		return
-	}
-
-	// Verify the token using the firebase package
-	token, err := utils.VerifyIDToken(idToken)
-	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-		log.Printf("Invalid token: %v", err)
-
-		return
-	}
-	fmt.Println("Verified user ID:", token.UID)
-
+func (h *Handler) HandleEntradasSubmit(w http.ResponseWriter, r *http.Request) {
 	// Limit file size (5MB)
 	if err := r.ParseMultipartForm(5 << 20); err != nil {
 		http.Error(w, "Error parsing form", http.StatusBadRequest)
@@ -48,52 +27,93 @@ func HandleEntradasSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract the base64Data from the "evidencia_recepcion" object
-	evidenciaObject := r.FormValue("evidencia_recepcion") // Contain object as string
-	// log.Println("Raw evidencia_recepcion string:", evidenciaObject) // Debug how does it look like
+	// Every field is checked before the upload so that a rejected request leaves
+	// nothing behind and lists all of its errors at once
+	ctx := r.Context()
+	var errs models.ValidationError
 
-	// Assuming the evidence object is passed as a JSON string, you can unmarshal it
-	var evidencia map[string]interface{}
-	if err := json.Unmarshal([]byte(evidenciaObject), &evidencia); err != nil {
-		http.Error(w, "Failed to parse evidencia_recepcion", http.StatusBadRequest)
-		log.Printf("Error parsing evidencia_recepcion: %v", err)
+	bodega, proveedor, err := h.resolveMovementCatalogs(ctx, r, &errs, "bodega_recepcion", "proveedor_recepcion")
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
 		return
 	}
 
-	// Extract the base64 data from the map
-	b64, ok := evidencia["base64Data"].(string)
-	if !ok || b64 == "" {
-		http.Error(w, "Missing base64 image", http.StatusBadRequest)
-		log.Println("Missing base64 image", err)
-		return
+	// Extract and decode the base64Data of the "evidencia_recepcion" object
+	var decoded []byte
+	var contentType string
+	if b64, msg := formImage(r, "evidencia_recepcion"); msg != "" {
+		errs.Add("evidencia_recepcion", msg)
+	} else if decoded, err = utils.DecodeB64(b64); err != nil {
+		errs.Add("evidencia_recepcion", "invalid base64Data")
+	} else if contentType = http.DetectContentType(decoded); !strings.HasPrefix(contentType, "image/") {
+		errs.Add("evidencia_recepcion", "must be an image")
 	}
 
-	// Decode the base64 string
-	decoded, err := utils.DecodeB64(b64)
+	lineItems, err := parseLineItems(r)
 	if err != nil {
-		http.Error(w, "B64 decoding error", http.StatusBadRequest)
-		return
+		errs.Add("line_items", err.Error())
 	}
 
-	contentType := http.DetectContentType(decoded)
-	if !strings.HasPrefix(contentType, "image/") {
-		http.Error(w, "Invalid image content", http.StatusBadRequest)
-		return
+	// With line items cantidad may be omitted, it is their total
+	var cant int64
+	if cantRaw := r.FormValue("cantidad"); cantRaw != "" {
+		if cant, err = strconv.ParseInt(cantRaw, 10, 64); err != nil {
+			errs.Add("cantidad", "must be an integer")
+		}
+	} else if len(lineItems) == 0 {
+		errs.Add("cantidad", "is required without line items")
+	}
+	if len(lineItems) > 0 {
+		total := models.LineItemsTotal(lineItems)
+		if cant != 0 && cant != total {
+			errs.Add("cantidad", fmt.Sprintf("%d does not match the line items total %d", cant, total))
+		}
+		cant = total
 	}
 
-	// Upload to GCS
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		http.Error(w, "GCS client error", http.StatusInternalServerError)
-		log.Printf("GCS client error: %v", err)
+	cliente := r.FormValue("cliente")
+	if cliente == "null" { //Not a devolución rma case
+		cliente = "N/A"
+	}
+
+	// A missing date is reported by the validation of the entrada
+	var fechaRecepcion time.Time
+	if fechaRaw := r.FormValue("fecha_recepcion"); fechaRaw != "" {
+		if fechaRecepcion, err = time.Parse(time.RFC3339, fechaRaw); err != nil {
+			errs.Add("fecha_recepcion", "must be an RFC 3339 date")
+		}
+	}
+
+	// Construct Entradas struct, the evidence URL is set after the upload
+	now := time.Now().UTC()
+	entrada := models.Entradas{
+		TipoDelivery:          r.FormValue("tipo_delivery"),
+		BodegaRecepcion:       bodega,
+		ProveedorRecepcion:    proveedor,
+		Cliente:               cliente,
+		NumeroRemisionFactura: r.FormValue("numero_remision_factura"),
+		PersonaRecepcion:      r.FormValue("persona_recepcion"),
+		FechaRecepcion:        fechaRecepcion,
+		Cantidad:              cant,
+		LineItems:             lineItems,
+		Comentarios:           r.FormValue("comentarios"),
+		Type:                  "entrada",
+		CreatedBy:             actorUID(r),
+		CreatedAt:             now,
+		UpdatedBy:             actorUID(r),
+		UpdatedAt:             now,
+	}
+	errs.Merge("", entrada.Validate(now))
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
 		return
 	}
-	defer client.Close()
 
+	// Upload to GCS
 	bucket := "app-entradas-salidas-merc"
 	object := fmt.Sprintf("evidencias_entradas/%s.jpeg", uuid.New().String())
-	wc := client.Bucket(bucket).Object(object).NewWriter(ctx)
+	wc := h.Storage.Bucket(bucket).Object(object).NewWriter(ctx)
 
 	// Set proper content type and metadata
 	wc.ContentType = contentType
@@ -118,110 +138,39 @@ func HandleEntradasSubmit(w http.ResponseWriter, r *http.Request) {
 	// Create public URL
 	imageURL := fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket, object)
 	log.Printf("File uploaded successfully to: %s with content type: %s", imageURL, contentType)
-
-	// Parse form values
-
-	//Validations Block
-	cant, err := strconv.ParseInt(r.FormValue("cantidad"), 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid quantity", http.StatusBadRequest)
-		log.Printf("Error parsing quantity: %v", err)
-		return
-	}
-
-	cliente := r.FormValue("cliente")
-	if cliente == "null" { //Not a devolución rma case
-		cliente = "N/A"
-	}
-
-	// Parse dates
-
-	// var fechaRecepcion time.Time
-	// fechaRaw := r.FormValue("fecha_recepcion")
-
-	// fechaRecepcion, err = time.Parse("2006-01-02T15:04:05.000-0700", fechaRaw)
-	// if err != nil {
-	// 	// Fallback if no timezone
-	// 	fechaRecepcion, err = time.Parse("2006-01-02T15:04:05.000", fechaRaw)
-	// 	if err != nil {
-	// 		http.Error(w, "Invalid fecha_recepcion format", http.StatusBadRequest)
-	// 		log.Printf("Error parsing fecha_recepcion: %v", err)
-	// 		return
-	// 	}
-	// }
-
-	fechaRaw := r.FormValue("fecha_recepcion")
-	fechaRecepcion, err := time.Parse(time.RFC3339, fechaRaw)
-	if err != nil {
-		http.Error(w, "Invalid fecha_recepcion format", http.StatusBadRequest)
-		log.Printf("Error parsing fecha_recepcion: %v", err)
-		return
-	}
-
-	// Construct Entradas struct
-	entrada := models.Entradas{
-		TipoDelivery:          r.FormValue("tipo_delivery"),
-		BodegaRecepcion:       r.FormValue("bodega_recepcion"),
-		ProveedorRecepcion:    r.FormValue("proveedor_recepcion"),
-		Cliente:               cliente,
-		NumeroRemisionFactura: r.FormValue("numero_remision_factura"),
-		PersonaRecepcion:      r.FormValue("persona_recepcion"),
-		FechaRecepcion:        fechaRecepcion,
-		EvidenciaRecepcion:    imageURL,
-		Cantidad:              cant,
-		Comentarios:           r.FormValue("comentarios"),
-		Type:                  "entrada",
-	}
-
-	// Save to Firestore
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
-	if err != nil {
-		http.Error(w, "Firestore error", http.StatusInternalServerError)
-		log.Printf("Firestore error: %v", err)
-		return
-	}
-	defer fsClient.Close()
-
-	//Email block
-	if entrada.TipoDelivery == "Devolución (RMA)" && entrada.Cliente != "N/A" {
-		utils.HandleClientEmailNotification(ctx, fsClient, entrada)
-		//replaced goroutine for testing
-	}
+	entrada.EvidenciaRecepcion = imageURL
 
 	// Add entrada form as new document to "entradas" collection
-	_, _, err = fsClient.Collection("entradas").Add(ctx, entrada)
+	id, err := h.Store.Entradas.Create(ctx, entrada)
 	if err != nil {
 		log.Printf("Error saving to Firestore: %v", err)
 		http.Error(w, fmt.Sprintf("Error saving to Firestore: %v", err), http.StatusInternalServerError)
 		return
 	}
+	h.Audit.Record(ctx, actorUID(r), models.AuditCreate, store.EntradasCollection, id, nil, entrada.Data())
+
+	//Email block, sent once the entrada has an ID for its reception certificate
+	if entrada.TipoDelivery == models.TipoDeliveryRMA && entrada.Cliente != "N/A" {
+		var attachments []utils.EmailAttachment
+		certificate, err := reports.EntradaCertificate(id, entrada.Data(), decoded)
+		if err != nil {
+			log.Printf("Error rendering reception certificate: %v", err)
+		} else {
+			attachments = append(attachments, utils.EmailAttachment{
+				Filename: fmt.Sprintf("acuse-recepcion-%s.pdf", id),
+				Content:  certificate,
+			})
+		}
+		utils.HandleClientEmailNotification(ctx, h.Store.Customers, entrada, attachments...)
+		//replaced goroutine for testing
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"message":"Entrada submitted successfully."}`))
 }
 
-func QueryEntrada(w http.ResponseWriter, r *http.Request) {
-	// Get token from header using utils
-	authHeader := r.Header.Get("Authorization")
-	idToken, err := utils.GetTokenFromHeader(authHeader)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		log.Printf("Invalid header token: %v", err)
-
-		return
-	}
-
-	// Verify the token using the firebase package
-	token, err := utils.VerifyIDToken(idToken)
-	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-		log.Printf("Invalid token: %v", err)
-
-		return
-	}
-	fmt.Println("Verified user ID:", token.UID)
-
+func (h *Handler) QueryEntrada(w http.ResponseWriter, r *http.Request) {
 	// Parse ID
 	ID := r.FormValue("id")
 	if ID == "" {
@@ -230,28 +179,22 @@ func QueryEntrada(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Initialize Firestore client
-	ctx := context.Background()
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
-	if err != nil {
-		http.Error(w, "Firestore error", http.StatusInternalServerError)
-		log.Printf("Firestore error: %v", err)
+	// Query the entrada by document ID
+	ctx := r.Context()
+	entrada, err := h.Store.Entradas.Get(ctx, ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
 		return
 	}
-	defer fsClient.Close()
-
-	// Build query and query firestore
-	docSnap, err := fsClient.Collection("entradas").Doc(ID).Get(ctx)
 	if err != nil {
 		log.Printf("Error querying Firestore: %v", err)
 		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
 		return
 	}
 
-	var entrada models.EntradasData
-	if err := docSnap.DataTo(&entrada); err != nil {
-		log.Printf("Error parsing Firestore document: %v", err)
-		http.Error(w, "Error processing data", http.StatusInternalServerError)
+	// Customer accounts only see their own movements
+	if cliente, scoped := customerScope(r); scoped && entrada.Cliente != cliente {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
 		return
 	}
 
@@ -266,120 +209,134 @@ func QueryEntrada(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func HandleASNSubmit(w http.ResponseWriter, r *http.Request) {
-	// Get token from header using utils
-	authHeader := r.Header.Get("Authorization")
-	idToken, err := utils.GetTokenFromHeader(authHeader)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		log.Printf("Invalid header token: %v", err)
-
+// QuerySalida is the salidas counterpart of QueryEntrada
+func (h *Handler) QuerySalida(w http.ResponseWriter, r *http.Request) {
+	// Parse ID
+	ID := r.FormValue("id")
+	if ID == "" {
+		http.Error(w, "Missing ID", http.StatusBadRequest)
+		log.Printf("Missing ID")
 		return
 	}
 
-	// Verify the token using the firebase package
-	token, err := utils.VerifyIDToken(idToken)
+	// Query the salida by document ID
+	ctx := r.Context()
+	salida, err := h.Store.Salidas.Get(ctx, ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-		log.Printf("Invalid token: %v", err)
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
 
+	// Customer accounts only see their own movements
+	if cliente, scoped := customerScope(r); scoped && salida.Cliente != cliente {
+		http.Error(w, "Salida not found", http.StatusNotFound)
 		return
 	}
-	fmt.Println("Verified user ID:", token.UID)
 
-	//Parse ASN update date
-	fechaAjusteASNRaw := r.FormValue("fecha_ajuste_asn")
-	FechaAjusteASN, err := time.Parse(time.RFC3339, fechaAjusteASNRaw)
-	if err != nil {
-		http.Error(w, "Invalid fecha_ajuste_asn format", http.StatusBadRequest)
-		log.Printf("Error parsing fecha_ajuste_asn: %v", err)
+	// Return JSON response, shaped like the salidas listing
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode([]models.SalidasDataWithID{{ID: ID, SalidasData: salida}}); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 
-	// FechaAjusteASN, err = time.Parse("2006-01-02T15:04:05.000-0700", fechaAjusteASNRaw)
-	// if err != nil {
-	// 	// Fallback if no timezone
-	// 	FechaAjusteASN, err = time.Parse("2006-01-02T15:04:05.000", fechaAjusteASNRaw)
-	// 	if err != nil {
-	// 		http.Error(w, "Invalid fecha_ajuste_asn format", http.StatusBadRequest)
-	// 		log.Printf("Error parsing fecha_ajuste_asn: %v", err)
-	// 		return
-	// 	}
-	// }
+}
 
-	// Parse document id
-	ID := r.FormValue("id")
-	if ID == "" {
-		http.Error(w, "Missing id", http.StatusBadRequest)
-		log.Printf("Missing id: %v", err)
-		return
+func (h *Handler) HandleASNSubmit(w http.ResponseWriter, r *http.Request) {
+	var errs models.ValidationError
+
+	//Parse ASN update date, a missing one is reported by the validation of the ASN
+	var FechaAjusteASN time.Time
+	if fechaAjusteASNRaw := r.FormValue("fecha_ajuste_asn"); fechaAjusteASNRaw != "" {
+		var err error
+		if FechaAjusteASN, err = time.Parse(time.RFC3339, fechaAjusteASNRaw); err != nil {
+			errs.Add("fecha_ajuste_asn", "must be an RFC 3339 date")
+		}
 	}
-	// Use id everywhere instead of ID
+
 	// Construct ASN struct
+	now := time.Now().UTC()
 	asn := models.ASN{
 		ID:             r.FormValue("id"),
-		ASN:            r.FormValue("asn"),
+		ASN:            strings.TrimSpace(r.FormValue("asn")),
 		FechaAjusteASN: FechaAjusteASN,
+		UpdatedBy:      actorUID(r),
+		UpdatedAt:      now,
 	}
-
-	// Initialize Firestore client
-	ctx := context.Background()
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
-	if err != nil {
-		http.Error(w, "Firestore error", http.StatusInternalServerError)
-		log.Printf("Firestore error: %v", err)
+	errs.Merge("", asn.Validate(now))
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
 		return
 	}
-	defer fsClient.Close()
+	ID := asn.ID
 
-	// Update entrada with ASN
-	// Find the document in "entradas" with the given numero_remision_factura
-	// iter := fsClient.Collection("entradas").Where("NumeroRemisionFactura", "==", asn.NumeroRemisionFactura).Limit(1).Documents(ctx)
-	// doc, err := iter.Next()
-	docSnap, err := fsClient.Collection("entradas").Doc(ID).Get(ctx)
-	if err != nil {
+	// Update the ASN and FechaAjusteASN fields of the entrada and record the change in its ASN history
+	ctx := r.Context()
+	before, err := h.Store.Entradas.UpdateASN(ctx, asn, r.FormValue("reason"))
+	if errors.Is(err, store.ErrNotFound) {
 		http.Error(w, "No matching entrada found", http.StatusNotFound)
 		log.Printf("No matching entrada found for id: %v", ID)
 		return
 	}
-
-	// Update the ASN and FechaAjusteASN fields
-	_, err = docSnap.Ref.Update(ctx, []firestore.Update{
-		{Path: "ASN", Value: asn.ASN},
-		{Path: "FechaAjusteASN", Value: asn.FechaAjusteASN},
-	})
+	if errors.Is(err, store.ErrDeleted) {
+		http.Error(w, "Entrada is deleted, restore it before updating the ASN", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to update ASN", http.StatusInternalServerError)
 		log.Printf("Failed to update ASN: %v", err)
 		return
 	}
 
+	after := before
+	after.ASN = asn.ASN
+	after.FechaAjusteASN = asn.FechaAjusteASN
+	after.UpdatedBy = asn.UpdatedBy
+	after.UpdatedAt = asn.UpdatedAt
+	h.Audit.Record(ctx, asn.UpdatedBy, models.AuditUpdate, store.EntradasCollection, ID, before, after)
+
+	// Notify the customer when the ASN of the entrada changed
+	if after.ASN != before.ASN && after.Cliente != "N/A" {
+		utils.HandleASNNotification(ctx, h.Store.Customers, after)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"message":"Entrada submitted successfully."}`))
 
 }
 
-func HandleSalidasSubmit(w http.ResponseWriter, r *http.Request) {
-	// Get token from header using utils
-	authHeader := r.Header.Get("Authorization")
-	idToken, err := utils.GetTokenFromHeader(authHeader)
+// HandleASNHistory returns the ASN changes of an entrada, oldest first
+func (h *Handler) HandleASNHistory(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	history, err := h.Store.Entradas.ASNHistory(r.Context(), ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "No matching entrada found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		log.Printf("Invalid header token: %v", err)
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
 		return
 	}
 
-	// Verify the token using the firebase package
-	token, err := utils.VerifyIDToken(idToken)
-	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-		log.Printf("Invalid token: %v", err)
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
-	fmt.Println("Verified user ID:", token.UID)
-	////////////////////////////////////////////////////////////////////////////
+}
 
+func (h *Handler) HandleSalidasSubmit(w http.ResponseWriter, r *http.Request) {
 	// Limit file size (5MB)
 	if err := r.ParseMultipartForm(5 << 20); err != nil {
 		http.Error(w, "Error parsing form", http.StatusBadRequest)
@@ -387,95 +344,40 @@ func HandleSalidasSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	////////////////////////////////////////////////////////////////////////////
-
-	// Extract and parse evidencia_recepcion as JSON string
-	evidenciaObject := r.FormValue("evidencia_salida")
-	log.Println("Raw evidencia_salida string:", evidenciaObject)
-
-	var evidencia map[string]interface{}
-	if err := json.Unmarshal([]byte(evidenciaObject), &evidencia); err != nil {
-		http.Error(w, "Failed to parse evidencia_salida", http.StatusBadRequest)
-		log.Printf("Error parsing evidencia_salida: %v", err)
+	// Only supervisors may let a salida take more stock than the inventory holds
+	stockOverrideBy, ok := stockOverride(w, r)
+	if !ok {
 		return
 	}
 
-	b64, ok := evidencia["base64Data"].(string)
-	if !ok || b64 == "" {
-		http.Error(w, "Missing base64 image", http.StatusBadRequest)
-		log.Printf("Missing base64 image: %v", err)
-		return
-	}
+	// Every field is checked before the uploads so that a rejected request leaves
+	// nothing behind and lists all of its errors at once
+	ctx := r.Context()
+	var errs models.ValidationError
 
-	// Decode and upload evidencia_salida
-	bucket := "app-entradas-salidas-merc"
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		http.Error(w, "GCS client error", http.StatusInternalServerError)
-		log.Printf("GCS client error: %v", err)
-		return
-	}
-	defer client.Close()
-
-	imageURL, err := utils.UploadImageToGCS(ctx, client, bucket, "evidencias_salidas", b64, "retool-app-salidas")
+	bodega, proveedor, err := h.resolveMovementCatalogs(ctx, r, &errs, "bodega_salida", "proveedor_salida")
 	if err != nil {
-		http.Error(w, "Image upload failed: "+err.Error(), http.StatusInternalServerError)
-		log.Printf("Image upload failed: %v", err)
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("File uploaded successfully to: %s", imageURL)
-
-	////////////////////////////////////////////////////////////////////////////
 
-	//Extract and parse signature as JSON string
-	signatureObject := r.FormValue("firma_persona_recoge")
-	log.Println("Raw firma_persona_recoge string:", signatureObject)
-	var firma map[string]interface{}
-	if err := json.Unmarshal([]byte(signatureObject), &firma); err != nil {
-		http.Error(w, "Failed to parse firma_persona_recoge", http.StatusBadRequest)
-		log.Printf("Error parsing firma_persona_recoge: %v", err)
-		return
+	// The evidence and the signature are JSON objects holding base64Data
+	b64, msg := formImage(r, "evidencia_salida")
+	if msg != "" {
+		errs.Add("evidencia_salida", msg)
 	}
-	b64Firma, ok := firma["base64Data"].(string)
-	if !ok || b64Firma == "" {
-		http.Error(w, "Missing base64 firma", http.StatusBadRequest)
-		log.Printf("Missing base64 firma: %v", err)
-		return
+	b64Firma, msg := formImage(r, "firma_persona_recoge")
+	if msg != "" {
+		errs.Add("firma_persona_recoge", msg)
 	}
 
-	// Decode and upload firma_persona_recoge
-	signatureImageURL, err := utils.UploadImageToGCS(ctx, client, bucket, "evidencias_salidas/salidas_firmas", b64Firma, "retool-app-salidas")
-	if err != nil {
-		http.Error(w, "Signature upload failed: "+err.Error(), http.StatusInternalServerError)
-		log.Printf("Signature upload failed: %v", err)
-		return
-	}
-	log.Printf("Signature uploaded successfully to: %s", signatureImageURL)
-
-	////////////////////////////////////////////////////////////////////////////
-
-	// Parse form values
-	// var fechaSalida time.Time
-	// fechaSalidaRaw := r.FormValue("fecha_salida")
-
-	// fechaSalida, err = time.Parse("2006-01-02T15:04:05.000-0700", fechaSalidaRaw)
-	// if err != nil {
-	// 	// Fallback if no timezone
-	// 	fechaSalida, err = time.Parse("2006-01-02T15:04:05.000", fechaSalidaRaw)
-	// 	if err != nil {
-	// 		http.Error(w, "Invalid fecha_salida format", http.StatusBadRequest)
-	// 		log.Printf("Error parsing fecha_salida: %v", err)
-	// 		return
-	// 	}
-	// }
-
-	fechaRaw := r.FormValue("fecha_salida")
-	fechaSalida, err := time.Parse(time.RFC3339, fechaRaw)
-	if err != nil {
-		http.Error(w, "Invalid fecha_salida format", http.StatusBadRequest)
-		log.Printf("Error parsing fecha_salida: %v", err)
-		return
+	// A missing date is reported by the validation of the salida
+	var fechaSalida time.Time
+	if fechaRaw := r.FormValue("fecha_salida"); fechaRaw != "" {
+		if fechaSalida, err = time.Parse(time.RFC3339, fechaRaw); err != nil {
+			errs.Add("fecha_salida", "must be an RFC 3339 date")
+		}
 	}
 
 	cliente := r.FormValue("cliente")
@@ -483,71 +385,102 @@ func HandleSalidasSubmit(w http.ResponseWriter, r *http.Request) {
 		cliente = "N/A"
 	}
 
-	// Construct Salidas struct
+	lineItems, err := parseLineItems(r)
+	if err != nil {
+		errs.Add("line_items", err.Error())
+	}
+
+	// Construct Salidas struct, the image URLs are set after the uploads
+	now := time.Now().UTC()
 	salida := models.Salidas{
-		BodegaSalida:           r.FormValue("bodega_salida"),
-		ProveedorSalida:        r.FormValue("proveedor_salida"),
+		BodegaSalida:           bodega,
+		ProveedorSalida:        proveedor,
 		Cliente:                cliente,
 		NumeroOrdenConsecutivo: r.FormValue("numero_orden_consecutivo"),
 		PersonaEntrega:         r.FormValue("persona_entrega"),
 		PersonaRecoge:          r.FormValue("persona_recoge"),
-		FirmaPersonaRecoge:     signatureImageURL,
 		FechaSalida:            fechaSalida,
-		EvidenciaSalida:        imageURL,
+		Cantidad:               models.LineItemsTotal(lineItems),
+		LineItems:              lineItems,
 		Comentarios:            r.FormValue("comentarios"),
 		Type:                   "salida",
+		StockOverrideBy:        stockOverrideBy,
+		CreatedBy:              actorUID(r),
+		CreatedAt:              now,
+		UpdatedBy:              actorUID(r),
+		UpdatedAt:              now,
+	}
+	errs.Merge("", salida.Validate(now))
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
 	}
 
-	// Save to Firestore
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
+	// Decode and upload evidencia_salida
+	bucket := "app-entradas-salidas-merc"
+	imageURL, err := utils.UploadImageToGCS(ctx, h.Storage, bucket, "evidencias_salidas", b64, "retool-app-salidas")
 	if err != nil {
-		http.Error(w, "Firestore error", http.StatusInternalServerError)
-		log.Printf("Firestore error: %v", err)
+		http.Error(w, "Image upload failed: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Image upload failed: %v", err)
 		return
 	}
-	defer fsClient.Close()
+	log.Printf("File uploaded successfully to: %s", imageURL)
+	salida.EvidenciaSalida = imageURL
+
+	// Decode and upload firma_persona_recoge
+	signatureImageURL, err := utils.UploadImageToGCS(ctx, h.Storage, bucket, "evidencias_salidas/salidas_firmas", b64Firma, "retool-app-salidas")
+	if err != nil {
+		http.Error(w, "Signature upload failed: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Signature upload failed: %v", err)
+		return
+	}
+	log.Printf("Signature uploaded successfully to: %s", signatureImageURL)
+	salida.FirmaPersonaRecoge = signatureImageURL
 
 	// Add entrada form as new document to "salidas" collection
-	_, _, err = fsClient.Collection("salidas").Add(ctx, salida)
+	id, err := h.Store.Salidas.Create(ctx, salida)
+	if insufficientStock(w, err) {
+		return
+	}
 	if err != nil {
 		log.Printf("Error saving to Firestore: %v", err)
 		http.Error(w, fmt.Sprintf("Error saving to Firestore: %v", err), http.StatusInternalServerError)
 		log.Printf("Error saving to Firestore: %v", err)
 		return
 	}
+	h.Audit.Record(ctx, actorUID(r), models.AuditCreate, store.SalidasCollection, id, nil, salida.Data())
+
+	// Email block, the delivery receipt goes attached. Images that cannot be
+	// decoded are left out of the receipt.
+	if salida.Cliente != "N/A" {
+		var attachments []utils.EmailAttachment
+		evidence, _ := utils.DecodeB64(b64)
+		signature, _ := utils.DecodeB64(b64Firma)
+		receipt, err := reports.SalidaReceipt(id, salida.Data(), signature, evidence)
+		if err != nil {
+			log.Printf("Error rendering delivery receipt: %v", err)
+		} else {
+			attachments = append(attachments, utils.EmailAttachment{
+				Filename: fmt.Sprintf("recibo-salida-%s.pdf", id),
+				Content:  receipt,
+			})
+		}
+		utils.HandleSalidaNotification(ctx, h.Store.Customers, salida, attachments...)
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"message":"Salida submitted successfully."}`))
 }
 
-func HandleCreateCustomer(w http.ResponseWriter, r *http.Request) {
-	// Get token from header using utils
-	authHeader := r.Header.Get("Authorization")
-	idToken, err := utils.GetTokenFromHeader(authHeader)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		log.Printf("Invalid header token: %v", err)
-
-		return
-	}
-
-	// Verify the token using the firebase package
-	token, err := utils.VerifyIDToken(idToken)
-	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-		log.Printf("Invalid token: %v", err)
-
-		return
-	}
-	fmt.Println("Verified user ID:", token.UID)
-
+func (h *Handler) HandleCreateCustomer(w http.ResponseWriter, r *http.Request) {
 	// Parse JSON body
 	var payload struct {
-		Cliente string `json:"cliente"`
-		Code    string `json:"code"`
-		Email   string `json:"email"`
-		RepName string `json:"rep_name"`
+		Cliente  string                   `json:"cliente"`
+		Code     string                   `json:"code"`
+		Email    string                   `json:"email"`
+		RepName  string                   `json:"rep_name"`
+		Contacts []models.CustomerContact `json:"contacts"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -556,37 +489,29 @@ func HandleCreateCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Validate required fields
-	if payload.Cliente == "" {
-		http.Error(w, "Missing 'cliente' field", http.StatusBadRequest)
-		log.Println("Missing 'cliente' field in request body")
-		return
+	customer := models.Customer{
+		ID:       payload.Cliente,
+		Code:     payload.Code,
+		Email:    payload.Email,
+		RepName:  payload.RepName,
+		Status:   models.CustomerActive,
+		Contacts: payload.Contacts,
 	}
-	if payload.Code == "" {
-		http.Error(w, "Missing 'code' field", http.StatusBadRequest)
-		log.Println("Missing 'code' field in request body")
-		return
-	}
-
-	data := map[string]interface{}{
-		"code":     payload.Code,
-		"email":    payload.Email,
-		"rep_name": payload.RepName,
+	//Validate required fields, cliente is the document ID
+	var errs models.ValidationError
+	if strings.TrimSpace(payload.Cliente) == "" {
+		errs.Add("cliente", "is required")
 	}
-
-	ctx := context.Background()
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
-	if err != nil {
-		http.Error(w, "Firestore error", http.StatusInternalServerError)
-		log.Printf("Firestore error: %v", err)
+	errs.Merge("", customer.Validate())
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
 		return
 	}
-	defer fsClient.Close()
 
 	// Use cliente as the document ID and create customer (fail if customer already exists)
-	_, err = fsClient.Collection("customers").Doc(payload.Cliente).Create(ctx, data)
-	if err != nil {
-		if status.Code(err) == codes.AlreadyExists {
+	ctx := r.Context()
+	if err := h.Store.Customers.Create(ctx, payload.Cliente, customer); err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
 			http.Error(w, "Customer already exists", http.StatusConflict)
 			log.Printf("Customer already exists: %s", payload.Cliente)
 			return
@@ -595,8 +520,10 @@ func HandleCreateCustomer(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error saving customer to Firestore: %v", err)
 		return
 	}
+	invalidateCustomers()
+	h.Audit.Record(ctx, actorUID(r), models.AuditCreate, store.CustomersCollection, payload.Cliente, nil, customer)
 
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"message":"Customer created or updated successfully."}`))
+	w.Write([]byte(`{"message":"Customer created successfully."}`))
 }
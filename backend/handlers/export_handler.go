@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+)
+
+// Rows written between two flushes of an export
+const exportFlushEvery = 500
+
+// Trailer set when an export stops early, the status is already sent by then
+const exportErrorTrailer = "X-Export-Error"
+
+var (
+	entradaCSVHeader = []string{
+		"id", "bodega_recepcion", "cantidad", "cliente", "proveedor_recepcion", "tipo_delivery",
+		"numero_remision_factura", "persona_recepcion", "fecha_recepcion", "asn", "fecha_ajuste_asn",
+		"comentarios", "evidencia_recepcion", "created_by", "created_at", "deleted_at",
+	}
+	salidaCSVHeader = []string{
+		"id", "bodega_salida", "cantidad", "cliente", "proveedor_salida", "numero_orden_consecutivo",
+		"persona_entrega", "persona_recoge", "fecha_salida", "comentarios", "evidencia_salida",
+		"created_by", "created_at", "deleted_at",
+	}
+)
+
+// Formats a timestamp for CSV, zero times are left empty
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func entradaCSVRow(e models.EntradasDataWithID) []string {
+	return []string{
+		e.ID, e.BodegaRecepcion, strconv.Itoa(e.Cantidad), e.Cliente, e.ProveedorRecepcion, e.TipoDelivery,
+		e.NumeroRemision, e.PersonaRecepcion, csvTime(e.FechaRecepcion), e.ASN, csvTime(e.FechaAjusteASN),
+		e.Comentarios, e.EvidenciaRecepcion, e.CreatedBy, csvTime(e.CreatedAt), csvTime(e.DeletedAt),
+	}
+}
+
+func salidaCSVRow(s models.SalidasDataWithID) []string {
+	return []string{
+		s.ID, s.BodegaSalida, strconv.Itoa(s.Cantidad), s.Cliente, s.ProveedorSalida, s.NumeroOrdenConsecutivo,
+		s.PersonaEntrega, s.PersonaRecoge, csvTime(s.FechaSalida), s.Comentarios, s.EvidenciaSalida,
+		s.CreatedBy, csvTime(s.CreatedAt), csvTime(s.DeletedAt),
+	}
+}
+
+// Picks the export format from the format parameter, then the Accept header.
+// NDJSON is the default.
+func exportFormat(r *http.Request) (string, bool) {
+	switch r.FormValue("format") {
+	case "csv":
+		return "csv", true
+	case "ndjson":
+		return "ndjson", true
+	case "":
+	default:
+		return "", false
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		return "csv", true
+	}
+	return "ndjson", true
+}
+
+// Writes export rows as CSV or NDJSON, flushing every few hundred rows so the
+// client starts receiving data while the Firestore iterator is still running
+type exportWriter struct {
+	csv     *csv.Writer
+	json    *json.Encoder
+	flusher http.Flusher
+	rows    int
+}
+
+func newExportWriter(w http.ResponseWriter, format, name string, header []string) *exportWriter {
+	ew := &exportWriter{}
+	ew.flusher, _ = w.(http.Flusher)
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Trailer", exportErrorTrailer)
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		ew.csv = csv.NewWriter(w)
+		ew.csv.Write(header)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		ew.json = json.NewEncoder(w)
+	}
+	return ew
+}
+
+// Writes one row, record is used for CSV and v for NDJSON
+func (ew *exportWriter) write(record []string, v interface{}) error {
+	var err error
+	if ew.csv != nil {
+		err = ew.csv.Write(record)
+	} else {
+		err = ew.json.Encode(v)
+	}
+	if err != nil {
+		return err
+	}
+	ew.rows++
+	if ew.rows%exportFlushEvery == 0 {
+		ew.flush()
+	}
+	return nil
+}
+
+// Marks an export that stopped early: a last record ({"error": ...} in NDJSON,
+// a row of "#error" and the message in CSV) and the X-Export-Error trailer, so
+// a truncated file cannot pass for a complete one
+func (ew *exportWriter) fail(w http.ResponseWriter, msg string) {
+	if ew.csv != nil {
+		ew.csv.Write([]string{"#error", msg})
+	} else {
+		ew.json.Encode(map[string]string{"error": msg})
+	}
+	ew.flush()
+	w.Header().Set(exportErrorTrailer, msg)
+}
+
+func (ew *exportWriter) flush() {
+	if ew.csv != nil {
+		ew.csv.Flush()
+	}
+	if ew.flusher != nil {
+		ew.flusher.Flush()
+	}
+}
+
+// HandleExportEntradas streams the entradas matching the listing parameters
+// as NDJSON or CSV (format=csv or Accept: text/csv). An export that fails
+// midway ends with an error record and the X-Export-Error trailer.
+func (h *Handler) HandleExportEntradas(w http.ResponseWriter, r *http.Request) {
+	// Use the request's context for proper cancellation
+	ctx := r.Context()
+
+	filter, msg := entradaFilterFromRequest(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		http.Error(w, "Invalid format, use 'csv' or 'ndjson'", http.StatusBadRequest)
+		return
+	}
+
+	ew := newExportWriter(w, format, "entradas", entradaCSVHeader)
+	err := h.Store.Entradas.Each(ctx, filter, func(entrada models.EntradasDataWithID) error {
+		return ew.write(entradaCSVRow(entrada), entrada)
+	})
+	if err != nil && ew.rows == 0 {
+		// Nothing was sent yet, the query itself failed
+		log.Printf("Error querying Firestore: %v", err)
+		w.Header().Del("Content-Disposition")
+		w.Header().Del("Trailer")
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	// The status is already sent, a failed export is marked at its end
+	if err != nil && !errors.Is(err, ctx.Err()) {
+		log.Printf("Error exporting entradas after %d rows: %v", ew.rows, err)
+		ew.fail(w, "export stopped after "+strconv.Itoa(ew.rows)+" rows: error querying Firestore")
+		return
+	}
+	ew.flush()
+}
+
+// HandleExportSalidas streams the salidas matching the listing parameters
+// as NDJSON or CSV (format=csv or Accept: text/csv). An export that fails
+// midway ends with an error record and the X-Export-Error trailer.
+func (h *Handler) HandleExportSalidas(w http.ResponseWriter, r *http.Request) {
+	// Use the request's context for proper cancellation
+	ctx := r.Context()
+
+	filter, msg := salidaFilterFromRequest(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		http.Error(w, "Invalid format, use 'csv' or 'ndjson'", http.StatusBadRequest)
+		return
+	}
+
+	ew := newExportWriter(w, format, "salidas", salidaCSVHeader)
+	err := h.Store.Salidas.Each(ctx, filter, func(salida models.SalidasDataWithID) error {
+		return ew.write(salidaCSVRow(salida), salida)
+	})
+	if err != nil && ew.rows == 0 {
+		// Nothing was sent yet, the query itself failed
+		log.Printf("Error querying Firestore: %v", err)
+		w.Header().Del("Content-Disposition")
+		w.Header().Del("Trailer")
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	// The status is already sent, a failed export is marked at its end
+	if err != nil && !errors.Is(err, ctx.Err()) {
+		log.Printf("Error exporting salidas after %d rows: %v", ew.rows, err)
+		ew.fail(w, "export stopped after "+strconv.Itoa(ew.rows)+" rows: error querying Firestore")
+		return
+	}
+	ew.flush()
+}
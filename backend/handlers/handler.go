@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"github.com/clopezbyte/app-entradas-salidas/audit"
+	"github.com/clopezbyte/app-entradas-salidas/middleware"
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/clopezbyte/app-entradas-salidas/utils"
+)
+
+// Handler holds the dependencies shared by the HTTP handlers.
+// The clients are created once by the app package and reused across requests.
+type Handler struct {
+	Store    *store.Store
+	Storage  *storage.Client
+	Verifier utils.TokenVerifier
+	Audit    *audit.Logger
+}
+
+// Returns the cliente a customer-readonly caller is limited to and whether the
+// request is scoped at all
+func customerScope(r *http.Request) (string, bool) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		return "", false
+	}
+	return p.CustomerScope()
+}
+
+// Returns the UID of the authenticated caller, recorded on every write
+func actorUID(r *http.Request) string {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+	return p.UID
+}
+
+// Reports whether the authenticated caller holds any of the given roles
+func hasRole(r *http.Request, roles ...string) bool {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		return false
+	}
+	return p.HasRole(roles...)
+}
+
+// Encodes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// Reads the override_negative_stock form value. Only supervisors and admins may
+// let a write take more stock than the inventory holds; it returns the caller's
+// UID when they did, and false when a response was written.
+func stockOverride(w http.ResponseWriter, r *http.Request) (string, bool) {
+	override, _ := strconv.ParseBool(r.FormValue("override_negative_stock"))
+	if !override {
+		return "", true
+	}
+	if !hasRole(r, middleware.RoleSupervisor, middleware.RoleAdmin) {
+		http.Error(w, "Forbidden: override_negative_stock requires the supervisor or admin role", http.StatusForbidden)
+		return "", false
+	}
+	return actorUID(r), true
+}
+
+// Answers 409 when err is a stock shortage, reporting whether it was one
+func insufficientStock(w http.ResponseWriter, err error) bool {
+	var stockErr *store.InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		return false
+	}
+	http.Error(w, fmt.Sprintf("Insufficient stock of %s in %s: %d available, %d requested",
+		stockErr.Key.SKU, stockErr.Key.Bodega, stockErr.Available, stockErr.Requested), http.StatusConflict)
+	return true
+}
+
+// Answers 400 listing the fields that failed validation as JSON. Other errors
+// are answered as plain text.
+func writeValidationError(w http.ResponseWriter, err error) {
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, struct {
+		Error  string              `json:"error"`
+		Fields []models.FieldError `json:"fields"`
+	}{"Validation failed", verr.Fields})
+}
+
+// Resolves a bodega to its canonical code in place, recording an unknown or
+// inactive one in errs. Empty values are left to the validation of the movement.
+func (h *Handler) resolveBodegaField(ctx context.Context, errs *models.ValidationError, field string, value *string) error {
+	if *value == "" {
+		return nil
+	}
+	code, msg, err := h.resolveBodega(ctx, *value)
+	if err != nil {
+		return err
+	}
+	if msg != "" {
+		errs.Add(field, msg)
+		return nil
+	}
+	*value = code
+	return nil
+}
+
+// Resolves a proveedor to its canonical code in place, recording an unknown or
+// inactive one in errs. Empty values are left to the validation of the movement.
+func (h *Handler) resolveProveedorField(ctx context.Context, errs *models.ValidationError, field string, value *string) error {
+	if *value == "" {
+		return nil
+	}
+	code, msg, err := h.resolveProveedor(ctx, *value)
+	if err != nil {
+		return err
+	}
+	if msg != "" {
+		errs.Add(field, msg)
+		return nil
+	}
+	*value = code
+	return nil
+}
+
+// Resolves the bodega and proveedor form values of a new movement to their
+// codes, recording unknown or inactive ones in errs
+func (h *Handler) resolveMovementCatalogs(ctx context.Context, r *http.Request, errs *models.ValidationError, bodegaField, proveedorField string) (string, string, error) {
+	bodega, proveedor := r.FormValue(bodegaField), r.FormValue(proveedorField)
+	if err := h.resolveBodegaField(ctx, errs, bodegaField, &bodega); err != nil {
+		return "", "", err
+	}
+	if err := h.resolveProveedorField(ctx, errs, proveedorField, &proveedor); err != nil {
+		return "", "", err
+	}
+	return bodega, proveedor, nil
+}
+
+// Reads the base64 data of an image form value, a JSON object with a
+// base64Data key. The message is empty when the data is present.
+func formImage(r *http.Request, field string) (string, string) {
+	var image map[string]interface{}
+	if err := json.Unmarshal([]byte(r.FormValue(field)), &image); err != nil {
+		return "", "must be a JSON object with base64Data"
+	}
+	b64, ok := image["base64Data"].(string)
+	if !ok || b64 == "" {
+		return "", "missing base64Data"
+	}
+	return b64, ""
+}
+
+// Parses the optional line_items form value, a JSON array of line items
+func parseLineItems(r *http.Request) ([]models.LineItem, error) {
+	raw := r.FormValue("line_items")
+	if raw == "" || raw == "null" {
+		return nil, nil
+	}
+	var items []models.LineItem
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return nil, fmt.Errorf("invalid line_items: %w", err)
+	}
+	if err := models.ValidateLineItems(items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/inventory"
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/reports"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/gorilla/mux"
+)
+
+// Reads the cliente, bodega and sku query parameters. Customer accounts are
+// limited to their own stock.
+func inventoryFilterFromRequest(r *http.Request) store.InventoryFilter {
+	filter := store.InventoryFilter{
+		Cliente: r.FormValue("cliente"),
+		Bodega:  r.FormValue("bodega"),
+		SKU:     r.FormValue("sku"),
+	}
+	if cliente, scoped := customerScope(r); scoped {
+		filter.Cliente = cliente
+	}
+	return filter
+}
+
+// HandleInventory returns the stock balances matching the optional bodega,
+// cliente and sku query parameters, ordered by cliente, bodega and SKU.
+// With as_of the balances are computed by replaying the movements dated before it.
+func (h *Handler) HandleInventory(w http.ResponseWriter, r *http.Request) {
+	filter := inventoryFilterFromRequest(r)
+
+	var balances []models.InventoryBalance
+	var err error
+	if raw := r.FormValue("as_of"); raw != "" {
+		asOf, parseErr := parseTimeParam(raw)
+		if parseErr != nil {
+			http.Error(w, "Invalid as_of format", http.StatusBadRequest)
+			return
+		}
+		balances, err = inventory.AsOf(r.Context(), h.Store, asOf, filter)
+	} else {
+		balances, err = h.Store.Inventory.List(r.Context(), filter)
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	if balances == nil {
+		balances = []models.InventoryBalance{}
+	}
+
+	writeJSON(w, http.StatusOK, balances)
+}
+
+// HandleCloseInventory computes and stores the closing snapshot of the month
+// given by the month and year query parameters. Closing a month again replaces
+// its snapshot, e.g. after late corrections.
+func (h *Handler) HandleCloseInventory(w http.ResponseWriter, r *http.Request) {
+	month, err := strconv.Atoi(r.FormValue("month"))
+	if err != nil || month < 1 || month > 12 {
+		http.Error(w, "Invalid month", http.StatusBadRequest)
+		return
+	}
+	year, err := strconv.Atoi(r.FormValue("year"))
+	if err != nil || year < 2000 {
+		http.Error(w, "Invalid year", http.StatusBadRequest)
+		return
+	}
+	if period, asOf := inventory.Period(year, time.Month(month)); asOf.After(time.Now()) {
+		http.Error(w, fmt.Sprintf("%s has not ended yet", period), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	snapshot, err := inventory.Close(ctx, h.Store, year, time.Month(month), actorUID(r))
+	if err != nil {
+		log.Printf("Failed to close inventory: %v", err)
+		http.Error(w, "Failed to close inventory", http.StatusInternalServerError)
+		return
+	}
+	// The balances are left out of the audit log, they can be read from the snapshot
+	logged := snapshot
+	logged.Balances = nil
+	h.Audit.Record(ctx, snapshot.CreatedBy, models.AuditCreate, store.SnapshotsCollection, snapshot.Period, nil, logged)
+
+	writeJSON(w, http.StatusCreated, snapshot)
+}
+
+// HandleListSnapshots returns the stored monthly closes, newest first, without their balances
+func (h *Handler) HandleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.Store.Snapshots.List(r.Context())
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	if snapshots == nil {
+		snapshots = []models.InventorySnapshot{}
+	}
+
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+// HandleGetSnapshot returns a monthly close with its balances matching the
+// optional bodega, cliente and sku query parameters
+func (h *Handler) HandleGetSnapshot(w http.ResponseWriter, r *http.Request) {
+	period := mux.Vars(r)["period"]
+
+	snapshot, err := h.Store.Snapshots.Get(r.Context(), period, inventoryFilterFromRequest(r))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Snapshot not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	if snapshot.Balances == nil {
+		snapshot.Balances = []models.InventoryBalance{}
+	}
+
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// HandleReconciliation compares a physical count, uploaded as a CSV in the
+// "file" form field, with the expected stock: the snapshot of period, the
+// stock replayed up to as_of, or the current balances when neither is given.
+// The optional cliente and bodega parameters narrow the expected stock to the
+// counted scope. Answers JSON lines, or an XLSX workbook with format=xlsx.
+func (h *Handler) HandleReconciliation(w http.ResponseWriter, r *http.Request) {
+	// Limit file size (5MB)
+	if err := r.ParseMultipartForm(5 << 20); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		log.Printf("Error parsing form: %v", err)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing count file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	counts, err := inventory.ParseCounts(file)
+	if err != nil {
+		http.Error(w, "Invalid count file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	filter := store.InventoryFilter{Cliente: r.FormValue("cliente"), Bodega: r.FormValue("bodega")}
+	report := reports.ReconciliationReport{}
+	var expected []models.InventoryBalance
+	switch {
+	case r.FormValue("period") != "":
+		snapshot, err := h.Store.Snapshots.Get(ctx, r.FormValue("period"), filter)
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Snapshot not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error querying Firestore: %v", err)
+			http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+			return
+		}
+		expected = snapshot.Balances
+		report.AsOf = snapshot.AsOf
+		report.Source = "Cierre " + snapshot.Period
+	case r.FormValue("as_of") != "":
+		asOf, err := parseTimeParam(r.FormValue("as_of"))
+		if err != nil {
+			http.Error(w, "Invalid as_of format", http.StatusBadRequest)
+			return
+		}
+		if expected, err = inventory.AsOf(ctx, h.Store, asOf, filter); err != nil {
+			log.Printf("Error querying Firestore: %v", err)
+			http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+			return
+		}
+		report.AsOf = asOf
+		report.Source = "Movimientos anteriores al corte"
+	default:
+		if expected, err = h.Store.Inventory.List(ctx, filter); err != nil {
+			log.Printf("Error querying Firestore: %v", err)
+			http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+			return
+		}
+		report.AsOf = time.Now().UTC()
+		report.Source = "Saldos actuales"
+	}
+	report.Lines = inventory.Reconcile(expected, counts)
+
+	if r.FormValue("format") != "xlsx" {
+		writeJSON(w, http.StatusOK, report.Lines)
+		return
+	}
+	workbook, err := report.Workbook()
+	if err != nil {
+		log.Printf("Error building report: %v", err)
+		http.Error(w, "Error building report", http.StatusInternalServerError)
+		return
+	}
+	writeWorkbook(w, workbook, report.Filename())
+}
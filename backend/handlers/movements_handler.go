@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/gorilla/mux"
+)
+
+// HandleGetEntrada returns one entrada with its document ID
+func (h *Handler) HandleGetEntrada(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	entrada, err := h.Store.Entradas.Get(r.Context(), ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	// Customer accounts only see their own movements
+	if cliente, scoped := customerScope(r); scoped && entrada.Cliente != cliente {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.EntradasDataWithID{ID: ID, EntradasData: entrada})
+}
+
+// HandlePatchEntrada corrects the fields sent in the JSON body of an entrada
+func (h *Handler) HandlePatchEntrada(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	// Only the fields of EntradasPatch can be corrected
+	var patch models.EntradasPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The corrected entrada must pass the validation of a new one, every bad
+	// field is reported at once
+	ctx := r.Context()
+	current, err := h.Store.Entradas.Get(ctx, ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	var errs models.ValidationError
+	if patch.BodegaRecepcion != nil {
+		if err := h.resolveBodegaField(ctx, &errs, "bodega_recepcion", patch.BodegaRecepcion); err != nil {
+			log.Printf("Error querying Firestore: %v", err)
+			http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+			return
+		}
+	}
+	if patch.ProveedorRecepcion != nil {
+		if err := h.resolveProveedorField(ctx, &errs, "proveedor_recepcion", patch.ProveedorRecepcion); err != nil {
+			log.Printf("Error querying Firestore: %v", err)
+			http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// The quantity of an entrada with line items is their total
+	switch {
+	case patch.LineItems == nil:
+		if total := models.LineItemsTotal(current.LineItems); patch.Cantidad != nil && len(current.LineItems) > 0 && *patch.Cantidad != total {
+			errs.Add("cantidad", fmt.Sprintf("%d does not match the line items total %d, correct the line items instead", *patch.Cantidad, total))
+		}
+	case len(*patch.LineItems) == 0:
+		// Clearing the line items needs the quantity they leave behind
+		if patch.Cantidad == nil {
+			errs.Add("cantidad", "is required when the line items are removed")
+		}
+	default:
+		total := models.LineItemsTotal(*patch.LineItems)
+		if patch.Cantidad != nil && *patch.Cantidad != total {
+			errs.Add("cantidad", fmt.Sprintf("%d does not match the line items total %d", *patch.Cantidad, total))
+		}
+		patch.Cantidad = &total
+	}
+
+	now := time.Now().UTC()
+	patch.UpdatedBy = actorUID(r)
+	patch.UpdatedAt = now
+	if !current.Deleted() {
+		errs.Merge("", patch.Apply(current).Validate(now))
+	}
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Read after the body so that a form-encoded body is not consumed
+	stockOverrideBy, ok := stockOverride(w, r)
+	if !ok {
+		return
+	}
+
+	ctx = store.WithStockOverride(ctx, stockOverrideBy)
+	before, after, err := h.Store.Entradas.Update(ctx, ID, patch)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrDeleted) {
+		http.Error(w, "Entrada is deleted, restore it before correcting it", http.StatusConflict)
+		return
+	}
+	if insufficientStock(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update entrada %s: %v", ID, err)
+		http.Error(w, "Failed to update entrada", http.StatusInternalServerError)
+		return
+	}
+	h.Audit.Record(ctx, patch.UpdatedBy, models.AuditUpdate, store.EntradasCollection, ID, before, after)
+
+	writeJSON(w, http.StatusOK, models.EntradasDataWithID{ID: ID, EntradasData: after})
+}
+
+// HandleDeleteEntrada voids an entrada, an optional reason is taken from the query string.
+// The document is kept with DeletedAt, DeletedBy and DeleteReason set.
+func (h *Handler) HandleDeleteEntrada(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	deletion := models.Deletion{
+		DeletedAt:    time.Now().UTC(),
+		DeletedBy:    actorUID(r),
+		DeleteReason: r.FormValue("reason"),
+	}
+
+	stockOverrideBy, ok := stockOverride(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := store.WithStockOverride(r.Context(), stockOverrideBy)
+	before, after, err := h.Store.Entradas.SoftDelete(ctx, ID, deletion)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrDeleted) {
+		http.Error(w, "Entrada is already deleted", http.StatusConflict)
+		return
+	}
+	if insufficientStock(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete entrada %s: %v", ID, err)
+		http.Error(w, "Failed to delete entrada", http.StatusInternalServerError)
+		return
+	}
+	h.Audit.Record(ctx, deletion.DeletedBy, models.AuditDelete, store.EntradasCollection, ID, before, after)
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Entrada deleted successfully."})
+}
+
+// HandleRestoreEntrada clears the deletion of a voided entrada
+func (h *Handler) HandleRestoreEntrada(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	stockOverrideBy, ok := stockOverride(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := store.WithStockOverride(r.Context(), stockOverrideBy)
+	before, after, err := h.Store.Entradas.Restore(ctx, ID, actorUID(r), time.Now().UTC())
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrNotDeleted) {
+		http.Error(w, "Entrada is not deleted", http.StatusConflict)
+		return
+	}
+	if insufficientStock(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to restore entrada %s: %v", ID, err)
+		http.Error(w, "Failed to restore entrada", http.StatusInternalServerError)
+		return
+	}
+	h.Audit.Record(ctx, after.UpdatedBy, models.AuditRestore, store.EntradasCollection, ID, before, after)
+
+	writeJSON(w, http.StatusOK, models.EntradasDataWithID{ID: ID, EntradasData: after})
+}
+
+// HandleGetSalida returns one salida with its document ID
+func (h *Handler) HandleGetSalida(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	salida, err := h.Store.Salidas.Get(r.Context(), ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	// Customer accounts only see their own movements
+	if cliente, scoped := customerScope(r); scoped && salida.Cliente != cliente {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.SalidasDataWithID{ID: ID, SalidasData: salida})
+}
+
+// HandlePatchSalida corrects the fields sent in the JSON body of a salida
+func (h *Handler) HandlePatchSalida(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	// Only the fields of SalidasPatch can be corrected. Cantidad is not one of
+	// them, it always follows the line items.
+	var patch models.SalidasPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The corrected salida must pass the validation of a new one, every bad
+	// field is reported at once
+	ctx := r.Context()
+	current, err := h.Store.Salidas.Get(ctx, ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	var errs models.ValidationError
+	if patch.BodegaSalida != nil {
+		if err := h.resolveBodegaField(ctx, &errs, "bodega_salida", patch.BodegaSalida); err != nil {
+			log.Printf("Error querying Firestore: %v", err)
+			http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+			return
+		}
+	}
+	if patch.ProveedorSalida != nil {
+		if err := h.resolveProveedorField(ctx, &errs, "proveedor_salida", patch.ProveedorSalida); err != nil {
+			log.Printf("Error querying Firestore: %v", err)
+			http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	patch.UpdatedBy = actorUID(r)
+	patch.UpdatedAt = now
+	if !current.Deleted() {
+		errs.Merge("", patch.Apply(current).Validate(now))
+	}
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Read after the body so that a form-encoded body is not consumed
+	stockOverrideBy, ok := stockOverride(w, r)
+	if !ok {
+		return
+	}
+
+	ctx = store.WithStockOverride(ctx, stockOverrideBy)
+	before, after, err := h.Store.Salidas.Update(ctx, ID, patch)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrDeleted) {
+		http.Error(w, "Salida is deleted, restore it before correcting it", http.StatusConflict)
+		return
+	}
+	if insufficientStock(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update salida %s: %v", ID, err)
+		http.Error(w, "Failed to update salida", http.StatusInternalServerError)
+		return
+	}
+	h.Audit.Record(ctx, patch.UpdatedBy, models.AuditUpdate, store.SalidasCollection, ID, before, after)
+
+	writeJSON(w, http.StatusOK, models.SalidasDataWithID{ID: ID, SalidasData: after})
+}
+
+// HandleDeleteSalida voids a salida, an optional reason is taken from the query string.
+// The document is kept with DeletedAt, DeletedBy and DeleteReason set.
+func (h *Handler) HandleDeleteSalida(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	deletion := models.Deletion{
+		DeletedAt:    time.Now().UTC(),
+		DeletedBy:    actorUID(r),
+		DeleteReason: r.FormValue("reason"),
+	}
+
+	stockOverrideBy, ok := stockOverride(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := store.WithStockOverride(r.Context(), stockOverrideBy)
+	before, after, err := h.Store.Salidas.SoftDelete(ctx, ID, deletion)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrDeleted) {
+		http.Error(w, "Salida is already deleted", http.StatusConflict)
+		return
+	}
+	if insufficientStock(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete salida %s: %v", ID, err)
+		http.Error(w, "Failed to delete salida", http.StatusInternalServerError)
+		return
+	}
+	h.Audit.Record(ctx, deletion.DeletedBy, models.AuditDelete, store.SalidasCollection, ID, before, after)
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Salida deleted successfully."})
+}
+
+// HandleRestoreSalida clears the deletion of a voided salida
+func (h *Handler) HandleRestoreSalida(w http.ResponseWriter, r *http.Request) {
+	ID := mux.Vars(r)["id"]
+
+	stockOverrideBy, ok := stockOverride(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := store.WithStockOverride(r.Context(), stockOverrideBy)
+	before, after, err := h.Store.Salidas.Restore(ctx, ID, actorUID(r), time.Now().UTC())
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrNotDeleted) {
+		http.Error(w, "Salida is not deleted", http.StatusConflict)
+		return
+	}
+	if insufficientStock(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to restore salida %s: %v", ID, err)
+		http.Error(w, "Failed to restore salida", http.StatusInternalServerError)
+		return
+	}
+	h.Audit.Record(ctx, after.UpdatedBy, models.AuditRestore, store.SalidasCollection, ID, before, after)
+
+	writeJSON(w, http.StatusOK, models.SalidasDataWithID{ID: ID, SalidasData: after})
+}
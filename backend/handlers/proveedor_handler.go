@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/gorilla/mux"
+)
+
+// Every proveedor, inactive ones included, cached like the customer IDs.
+// Writes through this API reset the cache.
+var (
+	proveedoresCache      []models.Proveedor
+	proveedoresCacheTime  time.Time
+	proveedoresCacheMutex sync.Mutex
+)
+
+// Returns the cached proveedores, reading them again when the cache expired or refresh is set
+func (h *Handler) cachedProveedores(ctx context.Context, refresh bool) ([]models.Proveedor, error) {
+	proveedoresCacheMutex.Lock()
+	defer proveedoresCacheMutex.Unlock()
+
+	if !refresh && time.Since(proveedoresCacheTime) < cacheDuration && proveedoresCache != nil {
+		return proveedoresCache, nil
+	}
+	proveedores, err := h.Store.Proveedores.List(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if proveedores == nil {
+		proveedores = []models.Proveedor{}
+	}
+	proveedoresCache = proveedores
+	proveedoresCacheTime = time.Now()
+	return proveedores, nil
+}
+
+// Drops the cached proveedores after a write
+func invalidateProveedores() {
+	proveedoresCacheMutex.Lock()
+	defer proveedoresCacheMutex.Unlock()
+	proveedoresCache = nil
+}
+
+// Finds the proveedor whose code, name or alias is value. A miss reads the
+// collection again, in case another instance registered it recently.
+func (h *Handler) findProveedor(ctx context.Context, value string) (models.Proveedor, error) {
+	for _, refresh := range []bool{false, true} {
+		proveedores, err := h.cachedProveedores(ctx, refresh)
+		if err != nil {
+			return models.Proveedor{}, err
+		}
+		for _, proveedor := range proveedores {
+			if proveedor.Matches(value) {
+				return proveedor, nil
+			}
+		}
+	}
+	return models.Proveedor{}, store.ErrNotFound
+}
+
+// Resolves a proveedor to its canonical code. The message is empty when it is a
+// registered, active proveedor.
+func (h *Handler) resolveProveedor(ctx context.Context, raw string) (string, string, error) {
+	proveedor, err := h.findProveedor(ctx, raw)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", fmt.Sprintf("unknown proveedor %q, it must be the code, name or an alias of a registered proveedor", raw), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if !proveedor.Active {
+		return "", fmt.Sprintf("proveedor %q is inactive", proveedor.Code), nil
+	}
+	return proveedor.Code, "", nil
+}
+
+// Checks that no other proveedor is known by one of the spellings of p, so
+// that every spelling resolves to a single proveedor. The returned message is
+// empty when there is no conflict.
+func (h *Handler) proveedorConflict(ctx context.Context, p models.Proveedor) (string, error) {
+	proveedores, err := h.cachedProveedores(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	for _, other := range proveedores {
+		if other.Code == p.Code {
+			continue
+		}
+		for _, spelling := range p.Spellings() {
+			if other.Matches(spelling) {
+				return fmt.Sprintf("%q is already used by proveedor %s", spelling, other.Code), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// HandleListProveedores returns the active proveedores ordered by code, every
+// one with include_inactive=true. Served from a cache refreshed every few minutes.
+func (h *Handler) HandleListProveedores(w http.ResponseWriter, r *http.Request) {
+	includeInactive, _ := strconv.ParseBool(r.FormValue("include_inactive"))
+
+	proveedores, err := h.cachedProveedores(r.Context(), false)
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	result := []models.Proveedor{}
+	for _, proveedor := range proveedores {
+		if proveedor.Active || includeInactive {
+			result = append(result, proveedor)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandleGetProveedor returns one proveedor
+func (h *Handler) HandleGetProveedor(w http.ResponseWriter, r *http.Request) {
+	code := models.NormalizeProveedorCode(mux.Vars(r)["code"])
+
+	proveedor, err := h.Store.Proveedores.Get(r.Context(), code)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Proveedor not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, proveedor)
+}
+
+// HandleCreateProveedor registers a proveedor from the JSON body, active
+// unless "active": false is sent
+func (h *Handler) HandleCreateProveedor(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Code    string   `json:"code"`
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+		Active  *bool    `json:"active"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	proveedor := models.Proveedor{
+		Code:      models.NormalizeProveedorCode(payload.Code),
+		Name:      payload.Name,
+		Aliases:   payload.Aliases,
+		Active:    payload.Active == nil || *payload.Active,
+		CreatedBy: actorUID(r),
+		CreatedAt: now,
+		UpdatedBy: actorUID(r),
+		UpdatedAt: now,
+	}
+	if proveedor.Aliases == nil {
+		proveedor.Aliases = []string{}
+	}
+	if err := proveedor.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	conflict, err := h.proveedorConflict(ctx, proveedor)
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	if conflict != "" {
+		http.Error(w, conflict, http.StatusConflict)
+		return
+	}
+
+	if err := h.Store.Proveedores.Create(ctx, proveedor); err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			http.Error(w, "Proveedor already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Error saving proveedor to Firestore: %v", err)
+		http.Error(w, "Error saving proveedor to Firestore", http.StatusInternalServerError)
+		return
+	}
+	invalidateProveedores()
+	h.Audit.Record(ctx, proveedor.CreatedBy, models.AuditCreate, store.ProveedoresCollection, proveedor.Code, nil, proveedor)
+
+	writeJSON(w, http.StatusCreated, proveedor)
+}
+
+// HandlePatchProveedor changes the fields sent in the JSON body of a proveedor
+func (h *Handler) HandlePatchProveedor(w http.ResponseWriter, r *http.Request) {
+	code := models.NormalizeProveedorCode(mux.Vars(r)["code"])
+
+	var patch models.ProveedorPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	patch.UpdatedBy = actorUID(r)
+	patch.UpdatedAt = time.Now().UTC()
+
+	h.updateProveedor(w, r, code, patch)
+}
+
+// HandleDeleteProveedor deactivates a proveedor. It is kept since movements
+// reference its code, but new movements can no longer name it.
+func (h *Handler) HandleDeleteProveedor(w http.ResponseWriter, r *http.Request) {
+	code := models.NormalizeProveedorCode(mux.Vars(r)["code"])
+
+	active := false
+	h.updateProveedor(w, r, code, models.ProveedorPatch{
+		Active:    &active,
+		UpdatedBy: actorUID(r),
+		UpdatedAt: time.Now().UTC(),
+	})
+}
+
+// Validates and applies a patch to a proveedor, answering the proveedor after it
+func (h *Handler) updateProveedor(w http.ResponseWriter, r *http.Request, code string, patch models.ProveedorPatch) {
+	ctx := r.Context()
+	current, err := h.Store.Proveedores.Get(ctx, code)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Proveedor not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	patched := patch.Apply(current)
+	if err := patched.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	conflict, err := h.proveedorConflict(ctx, patched)
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+	if conflict != "" {
+		http.Error(w, conflict, http.StatusConflict)
+		return
+	}
+
+	before, after, err := h.Store.Proveedores.Update(ctx, code, patch)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Proveedor not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update proveedor %s: %v", code, err)
+		http.Error(w, "Failed to update proveedor", http.StatusInternalServerError)
+		return
+	}
+	invalidateProveedores()
+	h.Audit.Record(ctx, patch.UpdatedBy, models.AuditUpdate, store.ProveedoresCollection, code, before, after)
+
+	writeJSON(w, http.StatusOK, after)
+}
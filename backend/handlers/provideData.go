@@ -2,262 +2,336 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
-	"sync"
 	"time"
 
-	"cloud.google.com/go/firestore"
 	"github.com/clopezbyte/app-entradas-salidas/models"
-	"github.com/clopezbyte/app-entradas-salidas/utils"
+	"github.com/clopezbyte/app-entradas-salidas/store"
 )
 
-func HandleProvideEntradasData(w http.ResponseWriter, r *http.Request) {
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
 
-	authHeader := r.Header.Get("Authorization")
-	idToken, err := utils.GetTokenFromHeader(authHeader)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized) // Error message from the utility function
-		return
-	}
+// Envelope of a paginated listing, NextPageToken is empty on the last page
+type page struct {
+	Items         interface{} `json:"items"`
+	NextPageToken string      `json:"next_page_token"`
+}
 
-	// Verify the token using the firebase package
-	token, err := utils.VerifyIDToken(idToken)
-	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-		return
+// Sort parameter values accepted by the listings, mapped to Firestore fields
+var (
+	entradaSortParams = map[string]string{
+		"fecha_recepcion":     "FechaRecepcion",
+		"cantidad":            "Cantidad",
+		"bodega_recepcion":    "BodegaRecepcion",
+		"cliente":             "Cliente",
+		"proveedor_recepcion": "ProveedorRecepcion",
+		"tipo_delivery":       "TipoDelivery",
+		"created_at":          "CreatedAt",
+	}
+	salidaSortParams = map[string]string{
+		"fecha_salida":     "FechaSalida",
+		"bodega_salida":    "BodegaSalida",
+		"cliente":          "Cliente",
+		"proveedor_salida": "ProveedorSalida",
+		"created_at":       "CreatedAt",
 	}
-	fmt.Println("Verified user ID:", token.UID)
+)
 
-	// Use the request's context for proper cancellation
-	ctx := r.Context()
+// Parses a from/to query parameter, either a date (2006-01-02) or RFC3339
+func parseTimeParam(raw string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
 
-	// Initialize Firestore client
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
-	if err != nil {
-		log.Printf("Error initializing Firestore client: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+// Reads the date range of a listing from either from/to or month/year.
+// The returned message is empty when the parameters are valid.
+func parseDateRange(r *http.Request) (time.Time, time.Time, string) {
+	var from, to time.Time
+	var err error
+
+	fromStr := r.FormValue("from")
+	toStr := r.FormValue("to")
+	if fromStr != "" || toStr != "" {
+		if fromStr != "" {
+			if from, err = parseTimeParam(fromStr); err != nil {
+				return from, to, "Invalid from format"
+			}
+		}
+		if toStr != "" {
+			if to, err = parseTimeParam(toStr); err != nil {
+				return from, to, "Invalid to format"
+			}
+		}
+		if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+			return from, to, "'from' must be before 'to'"
+		}
+		return from, to, ""
 	}
-	defer fsClient.Close()
 
-	// Get and validate query params
 	monthStr := r.FormValue("month")
 	yearStr := r.FormValue("year")
 	if monthStr == "" || yearStr == "" {
-		http.Error(w, "Missing 'month' or 'year' query parameter", http.StatusBadRequest)
-		return
+		return from, to, "Missing 'month' and 'year' or 'from'/'to' query parameters"
 	}
 
 	month, err := strconv.Atoi(monthStr)
 	if err != nil || month < 1 || month > 12 {
-		http.Error(w, "Invalid month", http.StatusBadRequest)
-		return
+		return from, to, "Invalid month"
 	}
 	year, err := strconv.Atoi(yearStr)
 	if err != nil || year < 2000 || year > time.Now().Year()+1 {
-		http.Error(w, "Invalid year", http.StatusBadRequest)
-		return
+		return from, to, "Invalid year"
 	}
 
-	startDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	endDate := startDate.AddDate(0, 1, 0) // first day of the next month
+	from = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	to = from.AddDate(0, 1, 0) // first day of the next month
+	return from, to, ""
+}
 
-	// Query Firestore for EntradasData within the specified date range
-	query := fsClient.Collection("entradas").
-		Where("FechaRecepcion", ">=", startDate).
-		Where("FechaRecepcion", "<", endDate).
-		OrderBy("FechaRecepcion", firestore.Asc)
-	docs, err := query.Documents(ctx).GetAll()
-	if err != nil {
-		log.Printf("Error querying Firestore: %v", err)
-		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
-		return
+// Reads the sort and order parameters against the accepted sort fields.
+// The returned message is empty when the parameters are valid.
+func parseSort(r *http.Request, fields map[string]string, descending bool) (string, bool, string) {
+	var field string
+	if sortStr := r.FormValue("sort"); sortStr != "" {
+		var ok bool
+		if field, ok = fields[sortStr]; !ok {
+			return "", false, "Invalid sort field"
+		}
+	}
+	switch r.FormValue("order") {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		return "", false, "Invalid order, use 'asc' or 'desc'"
 	}
+	return field, descending, ""
+}
+
+// Reads the entradas listing parameters: FechaRecepcion range (from/to or
+// month/year), bodega, cliente, proveedor, tipo_delivery, has_asn,
+// include_deleted, sort and order. The message is empty when they are valid.
+func entradaFilterFromRequest(r *http.Request) (store.EntradaFilter, string) {
+	var filter store.EntradaFilter
 
-	// Parse Firestore documents into a slice with ID
-	type EntradasDataWithID struct {
-		ID string `json:"id"`
-		models.EntradasData
+	from, to, msg := parseDateRange(r)
+	if msg != "" {
+		return filter, msg
+	}
+	sortBy, descending, msg := parseSort(r, entradaSortParams, false)
+	if msg != "" {
+		return filter, msg
 	}
 
-	// Parse Firestore documents into a slice of EntradasData
-	var results []EntradasDataWithID
-	for _, doc := range docs {
-		var entrada models.EntradasData
-		if err := doc.DataTo(&entrada); err != nil {
-			log.Printf("Error parsing Firestore document: %v", err)
-			http.Error(w, "Error processing data", http.StatusInternalServerError)
-			return
+	filter = store.EntradaFilter{
+		From:               from,
+		To:                 to,
+		BodegaRecepcion:    r.FormValue("bodega"),
+		Cliente:            r.FormValue("cliente"),
+		ProveedorRecepcion: r.FormValue("proveedor"),
+		TipoDelivery:       r.FormValue("tipo_delivery"),
+		SortBy:             sortBy,
+		Descending:         descending,
+		// Voided entradas are only listed with include_deleted=true
+		IncludeDeleted: r.FormValue("include_deleted") == "true",
+	}
+	if hasASN := r.FormValue("has_asn"); hasASN != "" {
+		v, err := strconv.ParseBool(hasASN)
+		if err != nil {
+			return filter, "Invalid has_asn"
 		}
-		results = append(results, EntradasDataWithID{
-			ID:           doc.Ref.ID,
-			EntradasData: entrada,
-		})
+		filter.HasASN = &v
 	}
 
-	// Return JSON response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(results); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-		return
+	// Customer accounts only see their own movements
+	if cliente, scoped := customerScope(r); scoped {
+		filter.Cliente = cliente
 	}
-
+	return filter, ""
 }
 
-func HandleProvideSalidasData(w http.ResponseWriter, r *http.Request) {
+// Reads the salidas listing parameters: FechaSalida range (from/to or
+// month/year), bodega, cliente, proveedor, include_deleted, sort and order.
+// Salidas are listed newest first by default. The message is empty when they are valid.
+func salidaFilterFromRequest(r *http.Request) (store.SalidaFilter, string) {
+	var filter store.SalidaFilter
 
-	authHeader := r.Header.Get("Authorization")
-	idToken, err := utils.GetTokenFromHeader(authHeader)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized) // Error message from the utility function
-		return
+	from, to, msg := parseDateRange(r)
+	if msg != "" {
+		return filter, msg
+	}
+	sortBy, descending, msg := parseSort(r, salidaSortParams, true)
+	if msg != "" {
+		return filter, msg
 	}
 
-	// Verify the token using the firebase package
-	token, err := utils.VerifyIDToken(idToken)
-	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-		return
+	filter = store.SalidaFilter{
+		From:            from,
+		To:              to,
+		BodegaSalida:    r.FormValue("bodega"),
+		Cliente:         r.FormValue("cliente"),
+		ProveedorSalida: r.FormValue("proveedor"),
+		SortBy:          sortBy,
+		Descending:      descending,
+		// Voided salidas are only listed with include_deleted=true
+		IncludeDeleted: r.FormValue("include_deleted") == "true",
 	}
-	fmt.Println("Verified user ID:", token.UID)
 
-	// Use the request's context for proper cancellation
-	ctx := r.Context()
+	// Customer accounts only see their own movements
+	if cliente, scoped := customerScope(r); scoped {
+		filter.Cliente = cliente
+	}
+	return filter, ""
+}
 
-	// Initialize Firestore client
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
-	if err != nil {
-		log.Printf("Error initializing Firestore client: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+// Reads page_size and page_token. size is defaultSize when page_size is not
+// given; the message is empty when the parameters are valid.
+func parsePage(r *http.Request, defaultSize int) (size int, token string, msg string) {
+	size = defaultSize
+	if sizeStr := r.FormValue("page_size"); sizeStr != "" {
+		var err error
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil || size < 1 || size > maxPageSize {
+			return 0, "", "Invalid page_size"
+		}
 	}
-	defer fsClient.Close()
+	return size, r.FormValue("page_token"), ""
+}
+
+// HandleProvideEntradasData lists entradas, see entradaFilterFromRequest for the
+// parameters. The response is a plain array unless page_size is given, in
+// which case it is a page envelope.
+func (h *Handler) HandleProvideEntradasData(w http.ResponseWriter, r *http.Request) {
+	h.listEntradas(w, r, 0)
+}
+
+// HandleListEntradas lists entradas in pages of page_size (default 100)
+func (h *Handler) HandleListEntradas(w http.ResponseWriter, r *http.Request) {
+	h.listEntradas(w, r, defaultPageSize)
+}
+
+func (h *Handler) listEntradas(w http.ResponseWriter, r *http.Request, defaultSize int) {
+	// Use the request's context for proper cancellation
+	ctx := r.Context()
 
 	// Get and validate query params
-	monthStr := r.FormValue("month")
-	yearStr := r.FormValue("year")
-	if monthStr == "" || yearStr == "" {
-		http.Error(w, "Missing 'month' or 'year' query parameter", http.StatusBadRequest)
+	filter, msg := entradaFilterFromRequest(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
-
-	month, err := strconv.Atoi(monthStr)
-	if err != nil || month < 1 || month > 12 {
-		http.Error(w, "Invalid month", http.StatusBadRequest)
+	filter.PageSize, filter.PageToken, msg = parsePage(r, defaultSize)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
-	year, err := strconv.Atoi(yearStr)
-	if err != nil || year < 2000 || year > time.Now().Year()+1 {
-		http.Error(w, "Invalid year", http.StatusBadRequest)
+
+	results, next, err := h.Store.Entradas.List(ctx, filter)
+	if errors.Is(err, store.ErrInvalidPageToken) {
+		http.Error(w, "Invalid page_token", http.StatusBadRequest)
 		return
 	}
-
-	startDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	endDate := startDate.AddDate(0, 1, 0) // first day of the next month
-
-	// Query Firestore for SalidasData within the specified date range
-	query := fsClient.Collection("salidas").
-		Where("FechaSalida", ">=", startDate).
-		Where("FechaSalida", "<", endDate).
-		OrderBy("FechaSalida", firestore.Desc)
-	docs, err := query.Documents(ctx).GetAll()
 	if err != nil {
 		log.Printf("Error querying Firestore: %v", err)
 		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
 		return
 	}
 
-	// Parse Firestore documents into a slice of EntradasData
-	var results []models.SalidasData
-	for _, doc := range docs {
-		var salida models.SalidasData
-		if err := doc.DataTo(&salida); err != nil {
-			log.Printf("Error parsing Firestore document: %v", err)
-			http.Error(w, "Error processing data", http.StatusInternalServerError)
-			return
-		}
-		results = append(results, salida)
-	}
-
 	// Return JSON response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(results); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	if filter.PageSize == 0 {
+		writeJSON(w, http.StatusOK, results)
 		return
 	}
+	if results == nil {
+		results = []models.EntradasDataWithID{}
+	}
+	writeJSON(w, http.StatusOK, page{Items: results, NextPageToken: next})
+}
 
+// HandleProvideSalidasData lists salidas, see salidaFilterFromRequest for the
+// parameters. The response is a plain array unless page_size is given, in
+// which case it is a page envelope.
+func (h *Handler) HandleProvideSalidasData(w http.ResponseWriter, r *http.Request) {
+	h.listSalidas(w, r, 0)
 }
 
-var (
-	customerIDsCache      []string
-	customerIDsCacheTime  time.Time
-	customerIDsCacheMutex sync.Mutex
-	cacheDuration         = 3 * time.Minute
-)
+// HandleListSalidas lists salidas in pages of page_size (default 100)
+func (h *Handler) HandleListSalidas(w http.ResponseWriter, r *http.Request) {
+	h.listSalidas(w, r, defaultPageSize)
+}
 
-func HandleProvideCustomers(w http.ResponseWriter, r *http.Request) {
-	customerIDsCacheMutex.Lock()
-	defer customerIDsCacheMutex.Unlock()
+func (h *Handler) listSalidas(w http.ResponseWriter, r *http.Request, defaultSize int) {
+	// Use the request's context for proper cancellation
+	ctx := r.Context()
 
-	// Serve from cache if not expired
-	if time.Since(customerIDsCacheTime) < cacheDuration && customerIDsCache != nil {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(customerIDsCache)
+	// Get and validate query params
+	filter, msg := salidaFilterFromRequest(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	filter.PageSize, filter.PageToken, msg = parsePage(r, defaultSize)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
 
-	authHeader := r.Header.Get("Authorization")
-	idToken, err := utils.GetTokenFromHeader(authHeader)
+	results, next, err := h.Store.Salidas.List(ctx, filter)
+	if errors.Is(err, store.ErrInvalidPageToken) {
+		http.Error(w, "Invalid page_token", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized) // Error message from the utility function
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
 		return
 	}
 
-	// Verify the token using the firebase package
-	token, err := utils.VerifyIDToken(idToken)
-	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+	// Return JSON response
+	if filter.PageSize == 0 {
+		writeJSON(w, http.StatusOK, results)
 		return
 	}
-	fmt.Println("Verified user ID:", token.UID)
+	if results == nil {
+		results = []models.SalidasDataWithID{}
+	}
+	writeJSON(w, http.StatusOK, page{Items: results, NextPageToken: next})
+}
+
+// How long the customer and proveedor listings are served from memory
+var cacheDuration = 3 * time.Minute
 
+// HandleProvideCustomers returns the IDs of the active customers, for the Retool dropdowns
+func (h *Handler) HandleProvideCustomers(w http.ResponseWriter, r *http.Request) {
 	// Use the request's context for proper cancellation
 	ctx := r.Context()
 
-	// Initialize Firestore client
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
-	if err != nil {
-		log.Printf("Error initializing Firestore client: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer fsClient.Close()
-
-	// Query Firestore for SalidasData within the specified date range
+	// Query customers
 	//cached
-	query := fsClient.Collection("customers")
-	docs, err := query.Documents(ctx).GetAll()
+	customers, err := h.cachedCustomers(ctx)
 	if err != nil {
 		log.Printf("Error querying Firestore: %v", err)
 		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
 		return
 	}
 
-	// Parse Firestore documents into a slice of Customers
-	var ids []string
-	for _, doc := range docs {
-		ids = append(ids, doc.Ref.ID)
+	ids := []string{}
+	for _, customer := range customers {
+		if customer.Active() {
+			ids = append(ids, customer.ID)
+		}
 	}
 
-	// Update cache
-	customerIDsCache = ids
-	customerIDsCacheTime = time.Now()
-
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(ids); err != nil {
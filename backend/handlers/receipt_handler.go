@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/clopezbyte/app-entradas-salidas/reports"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/clopezbyte/app-entradas-salidas/utils"
+	"github.com/gorilla/mux"
+)
+
+// Bucket holding the evidence images and the generated documents
+const evidenceBucket = "app-entradas-salidas-merc"
+
+// Downloads an evidence image for a document. Failures are logged and the
+// document is rendered without the image.
+func (h *Handler) documentImage(ctx context.Context, url string) []byte {
+	if url == "" {
+		return nil
+	}
+	data, err := utils.DownloadFromGCS(ctx, h.Storage, url)
+	if err != nil {
+		log.Printf("Error downloading image %s: %v", url, err)
+		return nil
+	}
+	return data
+}
+
+// Writes a generated PDF. With store=true staff callers also keep a copy in
+// GCS under object, whose URL is returned in the Content-Location header.
+func (h *Handler) writePDF(w http.ResponseWriter, r *http.Request, pdf []byte, object, filename string) {
+	if storeCopy, _ := strconv.ParseBool(r.FormValue("store")); storeCopy {
+		if _, scoped := customerScope(r); !scoped {
+			url, err := utils.UploadPDFToGCS(r.Context(), h.Storage, evidenceBucket, object, pdf, "api-documents")
+			if err != nil {
+				log.Printf("Error uploading document: %v", err)
+				http.Error(w, "Error uploading document", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Location", url)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	if _, err := w.Write(pdf); err != nil {
+		log.Printf("Error writing document: %v", err)
+	}
+}
+
+// HandleSalidaReceipt renders the delivery receipt of a salida as a PDF
+func (h *Handler) HandleSalidaReceipt(w http.ResponseWriter, r *http.Request) {
+	// Use the request's context for proper cancellation
+	ctx := r.Context()
+	ID := mux.Vars(r)["id"]
+
+	salida, err := h.Store.Salidas.Get(ctx, ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	// Customer accounts only see their own movements
+	if cliente, scoped := customerScope(r); scoped && salida.Cliente != cliente {
+		http.Error(w, "Salida not found", http.StatusNotFound)
+		return
+	}
+
+	signature := h.documentImage(ctx, salida.FirmaPersonaRecoge)
+	evidence := h.documentImage(ctx, salida.EvidenciaSalida)
+	pdf, err := reports.SalidaReceipt(ID, salida, signature, evidence)
+	if err != nil {
+		log.Printf("Error rendering receipt: %v", err)
+		http.Error(w, "Error rendering receipt", http.StatusInternalServerError)
+		return
+	}
+
+	// Stored next to the evidence images of the salida
+	h.writePDF(w, r, pdf, fmt.Sprintf("evidencias_salidas/recibos/%s.pdf", ID), fmt.Sprintf("recibo-salida-%s.pdf", ID))
+}
+
+// HandleEntradaCertificate renders the reception certificate of an entrada as a PDF
+func (h *Handler) HandleEntradaCertificate(w http.ResponseWriter, r *http.Request) {
+	// Use the request's context for proper cancellation
+	ctx := r.Context()
+	ID := mux.Vars(r)["id"]
+
+	entrada, err := h.Store.Entradas.Get(ctx, ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	// Customer accounts only see their own movements
+	if cliente, scoped := customerScope(r); scoped && entrada.Cliente != cliente {
+		http.Error(w, "Entrada not found", http.StatusNotFound)
+		return
+	}
+
+	evidence := h.documentImage(ctx, entrada.EvidenciaRecepcion)
+	pdf, err := reports.EntradaCertificate(ID, entrada, evidence)
+	if err != nil {
+		log.Printf("Error rendering reception certificate: %v", err)
+		http.Error(w, "Error rendering reception certificate", http.StatusInternalServerError)
+		return
+	}
+
+	// Stored next to the evidence images of the entrada
+	h.writePDF(w, r, pdf, fmt.Sprintf("evidencias_entradas/acuses/%s.pdf", ID), fmt.Sprintf("acuse-recepcion-%s.pdf", ID))
+}
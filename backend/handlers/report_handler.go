@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/reports"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/xuri/excelize/v2"
+)
+
+// HandleMovementsReport builds the XLSX workbook of the entradas and salidas
+// of one cliente over a period (from/to or month/year)
+func (h *Handler) HandleMovementsReport(w http.ResponseWriter, r *http.Request) {
+	// Use the request's context for proper cancellation
+	ctx := r.Context()
+
+	// Get and validate query params
+	from, to, msg := parseDateRange(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	// The title and filename name the period, it cannot be open-ended
+	if from.IsZero() || to.IsZero() {
+		http.Error(w, "Missing 'from' or 'to' query parameter, the report needs both", http.StatusBadRequest)
+		return
+	}
+	cliente := r.FormValue("cliente")
+	// Customer accounts only get their own report
+	if own, scoped := customerScope(r); scoped {
+		cliente = own
+	}
+	if cliente == "" {
+		http.Error(w, "Missing 'cliente' query parameter", http.StatusBadRequest)
+		return
+	}
+
+	report := reports.MovementsReport{Cliente: cliente, From: from, To: to}
+	err := h.Store.Entradas.Each(ctx, store.EntradaFilter{From: from, To: to, Cliente: cliente},
+		func(entrada models.EntradasDataWithID) error {
+			report.Entradas = append(report.Entradas, entrada)
+			return nil
+		})
+	if err == nil {
+		err = h.Store.Salidas.Each(ctx, store.SalidaFilter{From: from, To: to, Cliente: cliente},
+			func(salida models.SalidasDataWithID) error {
+				report.Salidas = append(report.Salidas, salida)
+				return nil
+			})
+	}
+	if err != nil {
+		log.Printf("Error querying Firestore: %v", err)
+		http.Error(w, "Error querying Firestore", http.StatusInternalServerError)
+		return
+	}
+
+	workbook, err := report.Workbook()
+	if err != nil {
+		log.Printf("Error building report: %v", err)
+		http.Error(w, "Error building report", http.StatusInternalServerError)
+		return
+	}
+	writeWorkbook(w, workbook, report.Filename())
+}
+
+// Writes a generated XLSX workbook as an attachment and closes it
+func writeWorkbook(w http.ResponseWriter, workbook *excelize.File, filename string) {
+	defer workbook.Close()
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := workbook.Write(w); err != nil {
+		log.Printf("Error writing report: %v", err)
+	}
+}
@@ -1,18 +1,17 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 
-	"cloud.google.com/go/firestore"
 	"github.com/clopezbyte/app-entradas-salidas/models"
-	"google.golang.org/api/iterator"
+	"github.com/clopezbyte/app-entradas-salidas/store"
 )
 
-func HandleRmaQuery(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HandleRmaQuery(w http.ResponseWriter, r *http.Request) {
 	// Validate API key
 	apiKey := os.Getenv("API_KEY")
 	token := r.Header.Get("Authorization")
@@ -28,24 +27,10 @@ func HandleRmaQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Initialize Firestore client
-	ctx := context.Background()
-	fsClient, err := firestore.NewClientWithDatabase(ctx, "b-materials", "app-in-out-good")
-	if err != nil {
-		http.Error(w, "Firestore error", http.StatusInternalServerError)
-		log.Printf("Firestore error: %v", err)
-		return
-	}
-	defer fsClient.Close()
-
-	// Query Firestore
-	iter := fsClient.Collection("entradas").
-		Where("ASN", "==", rmaRequest.Rma).
-		Limit(1).
-		Documents(ctx)
-
-	doc, err := iter.Next()
-	if err == iterator.Done {
+	// Query the entrada holding the ASN
+	ctx := r.Context()
+	entrada, err := h.Store.Entradas.FindByASN(ctx, rmaRequest.Rma)
+	if errors.Is(err, store.ErrNotFound) {
 		http.Error(w, "RMA or ASN not found", http.StatusNotFound)
 		return
 	}
@@ -55,13 +40,8 @@ func HandleRmaQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Map Firestore doc to response struct
-	var rmaResponse models.RmaResponse
-	if err := doc.DataTo(&rmaResponse); err != nil {
-		log.Printf("Error parsing Firestore document: %v", err)
-		http.Error(w, "Error processing data", http.StatusInternalServerError)
-		return
-	}
+	// Map entrada to response struct
+	rmaResponse := models.RmaResponse{Cliente: entrada.Cliente}
 
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
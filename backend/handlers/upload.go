@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,8 +10,7 @@ import (
 	"cloud.google.com/go/storage"
 )
 
-func GetSignedURL(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+func (h *Handler) GetSignedURL(w http.ResponseWriter, r *http.Request) {
 	bucket := "app-entradas-salidas-merc"
 	filename := r.URL.Query().Get("filename")
 	contentType := r.URL.Query().Get("contentType")
@@ -22,14 +20,7 @@ func GetSignedURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		http.Error(w, "storage client error", http.StatusInternalServerError)
-		return
-	}
-	defer client.Close()
-
-	url, err := storage.SignedURL(bucket, filename, &storage.SignedURLOptions{
+	url, err := h.Storage.Bucket(bucket).SignedURL(filename, &storage.SignedURLOptions{
 		Method:         "PUT",
 		Expires:        time.Now().Add(15 * time.Minute),
 		ContentType:    contentType,
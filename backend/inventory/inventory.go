@@ -0,0 +1,123 @@
+// Package inventory computes stock from the movements history: balances as of
+// a past date, monthly closing snapshots and reconciliations against a
+// physical count.
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+)
+
+// AsOf returns the balances matching the filter before asOf, ordered by
+// cliente, bodega and SKU. It starts from the latest monthly close at or
+// before asOf and replays the entradas and salidas dated after it. Voided
+// movements and movements without line items are left out, as they are from
+// the running balances.
+func AsOf(ctx context.Context, st *store.Store, asOf time.Time, filter store.InventoryFilter) ([]models.InventoryBalance, error) {
+	return replay(ctx, st, asOf, filter, "")
+}
+
+// Computes the balances before asOf, never starting from the close of the
+// skip period so that a month can be closed again
+func replay(ctx context.Context, st *store.Store, asOf time.Time, filter store.InventoryFilter, skip string) ([]models.InventoryBalance, error) {
+	balances := map[models.InventoryKey]models.InventoryBalance{}
+	from, err := startFrom(ctx, st, asOf, filter, skip, balances)
+	if err != nil {
+		return nil, err
+	}
+	add := func(deltas map[models.InventoryKey]int64, at time.Time) {
+		for key, delta := range deltas {
+			if filter.SKU != "" && key.SKU != filter.SKU {
+				continue
+			}
+			balance := balances[key]
+			balance.Cliente, balance.Bodega, balance.SKU = key.Cliente, key.Bodega, key.SKU
+			balance.Cantidad += delta
+			if at.After(balance.UpdatedAt) {
+				balance.UpdatedAt = at
+			}
+			balances[key] = balance
+		}
+	}
+
+	entradas := store.EntradaFilter{From: from, To: asOf, Cliente: filter.Cliente, BodegaRecepcion: filter.Bodega}
+	err = st.Entradas.Each(ctx, entradas, func(entrada models.EntradasDataWithID) error {
+		add(entrada.InventoryDeltas(), entrada.FechaRecepcion)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	salidas := store.SalidaFilter{From: from, To: asOf, Cliente: filter.Cliente, BodegaSalida: filter.Bodega}
+	err = st.Salidas.Each(ctx, salidas, func(salida models.SalidasDataWithID) error {
+		add(salida.InventoryDeltas(), salida.FechaSalida)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.InventoryBalance, 0, len(balances))
+	for _, balance := range balances {
+		result = append(result, balance)
+	}
+	models.SortBalances(result)
+	return result, nil
+}
+
+// Loads into balances the latest close at or before asOf, other than the skip
+// period, and returns the instant the replay continues from. Without such a
+// close the replay starts from the beginning of the history.
+func startFrom(ctx context.Context, st *store.Store, asOf time.Time, filter store.InventoryFilter, skip string, balances map[models.InventoryKey]models.InventoryBalance) (time.Time, error) {
+	snapshots, err := st.Snapshots.List(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Newest first
+	for _, snapshot := range snapshots {
+		if snapshot.Period == skip || snapshot.AsOf.After(asOf) {
+			continue
+		}
+		snapshot, err = st.Snapshots.Get(ctx, snapshot.Period, filter)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, balance := range snapshot.Balances {
+			balances[models.InventoryKey{Cliente: balance.Cliente, Bodega: balance.Bodega, SKU: balance.SKU}] = balance
+		}
+		return snapshot.AsOf, nil
+	}
+	return time.Time{}, nil
+}
+
+// Period returns the snapshot period of a month (YYYY-MM) and the instant its
+// close is computed at, the first of the following month in UTC
+func Period(year int, month time.Month) (string, time.Time) {
+	start := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return start.Format("2006-01"), start.AddDate(0, 1, 0)
+}
+
+// Close computes the closing inventory of a month and saves it as its
+// snapshot, replacing a previous close of the same month. Callers make sure
+// the month has ended.
+func Close(ctx context.Context, st *store.Store, year int, month time.Month, actor string) (models.InventorySnapshot, error) {
+	period, asOf := Period(year, month)
+	balances, err := replay(ctx, st, asOf, store.InventoryFilter{}, period)
+	if err != nil {
+		return models.InventorySnapshot{}, err
+	}
+	snapshot := models.InventorySnapshot{
+		Period:    period,
+		AsOf:      asOf,
+		Balances:  balances,
+		CreatedBy: actor,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := st.Snapshots.Save(ctx, snapshot); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
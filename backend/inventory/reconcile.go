@@ -0,0 +1,109 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+)
+
+// Columns a physical count CSV must have, in any order. Other columns are ignored.
+var countColumns = []string{"cliente", "bodega", "sku", "cantidad"}
+
+// ParseCounts reads a physical count CSV with a header row. Lines repeating a
+// cliente, bodega and SKU are added up, e.g. when a SKU sits in several racks.
+func ParseCounts(r io.Reader) ([]models.InventoryCount, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("count file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		// Spreadsheets often save the header with a byte order mark
+		name = strings.TrimPrefix(name, "\ufeff")
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, column := range countColumns {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("count file is missing the %q column", column)
+		}
+	}
+
+	totals := map[models.InventoryKey]int64{}
+	var keys []models.InventoryKey
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		key := models.InventoryKey{
+			Cliente: strings.TrimSpace(record[index["cliente"]]),
+			Bodega:  strings.TrimSpace(record[index["bodega"]]),
+			SKU:     strings.TrimSpace(record[index["sku"]]),
+		}
+		if key.Cliente == "" || key.Bodega == "" || key.SKU == "" {
+			return nil, fmt.Errorf("line %d: cliente, bodega and sku are required", line)
+		}
+		qty, err := strconv.ParseInt(strings.TrimSpace(record[index["cantidad"]]), 10, 64)
+		if err != nil || qty < 0 {
+			return nil, fmt.Errorf("line %d: invalid cantidad %q", line, record[index["cantidad"]])
+		}
+		if _, seen := totals[key]; !seen {
+			keys = append(keys, key)
+		}
+		totals[key] += qty
+	}
+
+	counts := make([]models.InventoryCount, 0, len(keys))
+	for _, key := range keys {
+		counts = append(counts, models.InventoryCount{Cliente: key.Cliente, Bodega: key.Bodega, SKU: key.SKU, Cantidad: totals[key]})
+	}
+	return counts, nil
+}
+
+// Reconcile compares the expected balances with a physical count. Every
+// balance and every counted SKU gets a line, missing on either side counts as
+// zero. Lines are ordered by cliente, bodega and SKU.
+func Reconcile(expected []models.InventoryBalance, counts []models.InventoryCount) []models.ReconciliationLine {
+	lines := map[models.InventoryKey]*models.ReconciliationLine{}
+	line := func(key models.InventoryKey) *models.ReconciliationLine {
+		l, ok := lines[key]
+		if !ok {
+			l = &models.ReconciliationLine{Cliente: key.Cliente, Bodega: key.Bodega, SKU: key.SKU}
+			lines[key] = l
+		}
+		return l
+	}
+	for _, balance := range expected {
+		line(models.InventoryKey{Cliente: balance.Cliente, Bodega: balance.Bodega, SKU: balance.SKU}).Expected += balance.Cantidad
+	}
+	for _, count := range counts {
+		line(models.InventoryKey{Cliente: count.Cliente, Bodega: count.Bodega, SKU: count.SKU}).Counted += count.Cantidad
+	}
+
+	result := make([]models.ReconciliationLine, 0, len(lines))
+	for _, l := range lines {
+		l.Difference = l.Counted - l.Expected
+		result = append(result, *l)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		keyA := models.InventoryKey{Cliente: a.Cliente, Bodega: a.Bodega, SKU: a.SKU}
+		return keyA.Less(models.InventoryKey{Cliente: b.Cliente, Bodega: b.Bodega, SKU: b.SKU})
+	})
+	return result
+}
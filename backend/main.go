@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/clopezbyte/app-entradas-salidas/app"
 	"github.com/clopezbyte/app-entradas-salidas/routes"
 )
 
@@ -15,10 +21,34 @@ func main() {
 	// 	log.Fatal("Error loading .env file")
 	// }
 
-	router := routes.SetupRouter()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	log.Println("Starting server on :8080...")
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		log.Fatal(err)
+	a, err := app.New(ctx)
+	if err != nil {
+		log.Fatalf("Error initializing app: %v", err)
+	}
+	defer a.Close()
+
+	server := &http.Server{
+		Addr:    ":8080",
+		Handler: routes.SetupRouter(a.Handler()),
+	}
+
+	go func() {
+		log.Println("Starting server on :8080...")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// Wait for Cloud Run (SIGTERM) or Ctrl+C before draining requests
+	<-ctx.Done()
+	log.Println("Shutting down server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
 	}
 }
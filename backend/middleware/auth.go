@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/clopezbyte/app-entradas-salidas/utils"
+	"github.com/gorilla/mux"
+)
+
+// Principal is the verified caller of a protected route
+type Principal struct {
+	UID    string
+	Email  string
+	Roles  []string
+	Claims map[string]interface{}
+}
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the principal stored by Authenticate, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok && p != nil
+}
+
+// NewPrincipal builds a Principal from a verified Firebase token
+func NewPrincipal(token *auth.Token) *Principal {
+	email, _ := token.Claims["email"].(string)
+	return &Principal{
+		UID:    token.UID,
+		Email:  email,
+		Roles:  rolesFromClaims(token.Claims),
+		Claims: token.Claims,
+	}
+}
+
+// Authenticate verifies the Bearer ID token once per request and stores the
+// resulting Principal in the request context. Requests without a valid token
+// are rejected with 401 before reaching the handler.
+func Authenticate(verifier utils.TokenVerifier) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get token from header using utils
+			idToken, err := utils.GetTokenFromHeader(r.Header.Get("Authorization"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				log.Printf("Invalid header token: %v", err)
+				return
+			}
+
+			// Verify the token with the configured verifier
+			token, err := verifier.VerifyIDToken(r.Context(), idToken)
+			if err != nil {
+				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				log.Printf("Invalid token: %v", err)
+				return
+			}
+
+			ctx := WithPrincipal(r.Context(), NewPrincipal(token))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
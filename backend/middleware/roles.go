@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Roles assigned to users through Firebase custom claims, either as a single
+// "role" string or as a "roles" array
+const (
+	RoleOperator         = "operator"
+	RoleSupervisor       = "supervisor"
+	RoleAdmin            = "admin"
+	RoleCustomerReadOnly = "customer-readonly"
+)
+
+// Reads the roles from the custom claims of the token
+func rolesFromClaims(claims map[string]interface{}) []string {
+	var roles []string
+	if role, ok := claims["role"].(string); ok && role != "" {
+		roles = append(roles, role)
+	}
+	if list, ok := claims["roles"].([]interface{}); ok {
+		for _, v := range list {
+			if role, ok := v.(string); ok && role != "" {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// HasRole reports whether the principal holds any of the given roles
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CustomerScope returns the cliente claim of a customer-readonly principal and
+// true when its reads must be limited to that cliente. Internal users get false.
+// RequireRoles rejects the scoped principals without a cliente claim, so the
+// handlers never see an empty scope.
+func (p *Principal) CustomerScope() (string, bool) {
+	if p.HasRole(RoleOperator, RoleSupervisor, RoleAdmin) || !p.HasRole(RoleCustomerReadOnly) {
+		return "", false
+	}
+	cliente, _ := p.Claims["cliente"].(string)
+	return cliente, true
+}
+
+// RequireRoles rejects with 403 the requests whose principal holds none of the
+// given roles. It must run after Authenticate.
+func RequireRoles(roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Forbidden: request is not authenticated", http.StatusForbidden)
+				return
+			}
+
+			if !p.HasRole(roles...) {
+				reason := fmt.Sprintf("Forbidden: %s requires one of the roles [%s]", r.URL.Path, strings.Join(roles, ", "))
+				if len(p.Roles) == 0 {
+					reason += ", user has no role assigned"
+				} else {
+					reason += fmt.Sprintf(", user has [%s]", strings.Join(p.Roles, ", "))
+				}
+				http.Error(w, reason, http.StatusForbidden)
+				log.Printf("Access denied for user %s on %s %s", p.UID, r.Method, r.URL.Path)
+				return
+			}
+
+			// An empty cliente would read as no filter at all
+			if cliente, scoped := p.CustomerScope(); scoped && cliente == "" {
+				http.Error(w, "Forbidden: customer account has no cliente claim", http.StatusForbidden)
+				log.Printf("Access denied for user %s on %s %s, no cliente claim", p.UID, r.Method, r.URL.Path)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
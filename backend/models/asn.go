@@ -8,4 +8,26 @@ type ASN struct {
 	ID             string    `json:"id"`
 	ASN            string    `json:"asn"`
 	FechaAjusteASN time.Time `json:"fecha_ajuste_asn"`
+	UpdatedBy      string    `json:"updated_by"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Validate checks an ASN assignment, dates are compared with now
+func (a ASN) Validate(now time.Time) error {
+	return validate(
+		required("id", a.ID),
+		required("asn", a.ASN),
+		date("fecha_ajuste_asn", a.FechaAjusteASN, now),
+	)
+}
+
+// ASNChange is one entry of the "asn_history" subcollection of an entrada
+type ASNChange struct {
+	ID             string    `firestore:"-" json:"id"`
+	OldASN         string    `firestore:"old_asn" json:"old_asn"`
+	NewASN         string    `firestore:"new_asn" json:"new_asn"`
+	FechaAjusteASN time.Time `firestore:"fecha_ajuste_asn" json:"fecha_ajuste_asn"`
+	ChangedBy      string    `firestore:"changed_by" json:"changed_by"`
+	ChangedAt      time.Time `firestore:"changed_at" json:"changed_at"`
+	Reason         string    `firestore:"reason" json:"reason"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Actions recorded in the audit log
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+type AuditEntry struct {
+	ID         string                 `firestore:"-" json:"id"`
+	Actor      string                 `firestore:"actor" json:"actor"`
+	Action     string                 `firestore:"action" json:"action"`
+	Collection string                 `firestore:"collection" json:"collection"`
+	DocumentID string                 `firestore:"document_id" json:"document_id"`
+	Changes    map[string]FieldChange `firestore:"changes" json:"changes"`
+	Timestamp  time.Time              `firestore:"timestamp" json:"timestamp"`
+}
+
+// FieldChange holds the value of a field before and after a mutation
+type FieldChange struct {
+	Before interface{} `firestore:"before" json:"before"`
+	After  interface{} `firestore:"after" json:"after"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Bodega is a warehouse of the "bodegas" collection, keyed by its code.
+// Movements store the code in BodegaRecepcion and BodegaSalida.
+type Bodega struct {
+	Code      string    `json:"code" firestore:"code"`
+	Name      string    `json:"name" firestore:"name"`
+	Address   string    `json:"address" firestore:"address"`
+	Timezone  string    `json:"timezone" firestore:"timezone"` // IANA name, e.g. America/Mexico_City
+	Active    bool      `json:"active" firestore:"active"`
+	CreatedBy string    `json:"created_by" firestore:"created_by"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedBy string    `json:"updated_by" firestore:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// NormalizeBodegaCode returns the canonical form of a bodega code: trimmed and upper case
+func NormalizeBodegaCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate checks the fields every bodega needs, reporting every bad field
+func (b Bodega) Validate() error {
+	return validate(
+		required("code", b.Code),
+		code("code", b.Code),
+		required("name", b.Name),
+		custom("timezone", func() string {
+			if _, err := time.LoadLocation(b.Timezone); b.Timezone == "" || err != nil {
+				return "must be an IANA time zone, e.g. America/Mexico_City"
+			}
+			return ""
+		}),
+	)
+}
+
+// BodegaPatch holds the changes to a bodega, nil fields are left unchanged.
+// The code cannot change since movements reference it.
+type BodegaPatch struct {
+	Name      *string   `json:"name"`
+	Address   *string   `json:"address"`
+	Timezone  *string   `json:"timezone"`
+	Active    *bool     `json:"active"`
+	UpdatedBy string    `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// Fields returns the Firestore fields set by the patch
+func (p BodegaPatch) Fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"updated_by": p.UpdatedBy,
+		"updated_at": p.UpdatedAt,
+	}
+	if p.Name != nil {
+		fields["name"] = *p.Name
+	}
+	if p.Address != nil {
+		fields["address"] = *p.Address
+	}
+	if p.Timezone != nil {
+		fields["timezone"] = *p.Timezone
+	}
+	if p.Active != nil {
+		fields["active"] = *p.Active
+	}
+	return fields
+}
+
+// Apply returns a copy of the bodega with the patch applied
+func (p BodegaPatch) Apply(b Bodega) Bodega {
+	if p.Name != nil {
+		b.Name = *p.Name
+	}
+	if p.Address != nil {
+		b.Address = *p.Address
+	}
+	if p.Timezone != nil {
+		b.Timezone = *p.Timezone
+	}
+	if p.Active != nil {
+		b.Active = *p.Active
+	}
+	b.UpdatedBy = p.UpdatedBy
+	b.UpdatedAt = p.UpdatedAt
+	return b
+}
@@ -0,0 +1,9 @@
+package models
+
+import "strings"
+
+// FoldSpelling folds the spellings of a catalog entry, such as a bodega or a
+// proveedor, that only differ in case or spacing
+func FoldSpelling(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
@@ -1,7 +1,176 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Customer statuses. Documents created before statuses existed have none and count as active.
+const (
+	CustomerActive   = "active"
+	CustomerInactive = "inactive"
+)
+
+// Events a customer contact can subscribe to
+const (
+	EventRMAReceived      = "rma_received"
+	EventASNAssigned      = "asn_assigned"
+	EventSalidaDispatched = "salida_dispatched"
+)
+
+// How a contact receives the notifications
+const (
+	DeliveryTo  = "to"
+	DeliveryCC  = "cc"
+	DeliveryBCC = "bcc"
+)
+
+// Languages notifications are written in
+const (
+	LanguageES = "es"
+	LanguageEN = "en"
+)
+
+// CustomerContact is a person notified about the movements of a customer
+type CustomerContact struct {
+	Name     string   `firestore:"name" json:"name"`
+	Email    string   `firestore:"email" json:"email"`
+	Role     string   `firestore:"role" json:"role"`         // free text, e.g. "compras"
+	Delivery string   `firestore:"delivery" json:"delivery"` // to, cc or bcc, to when empty
+	Language string   `firestore:"language" json:"language"` // es or en, es when empty
+	Events   []string `firestore:"events" json:"events"`
+}
+
+// Subscribed reports whether the contact wants the notifications of the event
+func (c CustomerContact) Subscribed(event string) bool {
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Rules of the i-th contact, its fields are reported as field[i].email and so on
+func (c CustomerContact) rules(field string, i int) []rule {
+	rules := []rule{
+		required(indexed(field, i, "email"), c.Email),
+		email(indexed(field, i, "email"), c.Email),
+		oneOf(indexed(field, i, "delivery"), c.Delivery, []string{DeliveryTo, DeliveryCC, DeliveryBCC}),
+		oneOf(indexed(field, i, "language"), c.Language, []string{LanguageES, LanguageEN}),
+	}
+	for j, event := range c.Events {
+		rules = append(rules, oneOf(fmt.Sprintf("%s[%d]", indexed(field, i, "events"), j), event,
+			[]string{EventRMAReceived, EventASNAssigned, EventSalidaDispatched}))
+	}
+	return rules
+}
+
+// Customer is a document of the "customers" collection, keyed by cliente.
+// Email and RepName predate contacts and are only used when there are none.
 type Customer struct {
-	Code    string `firestore:"code"`
-	Email   string `firestore:"email"`
-	RepName string `firestore:"rep_name"`
+	ID       string            `firestore:"-" json:"id"` // cliente
+	Code     string            `firestore:"code" json:"code"`
+	Email    string            `firestore:"email" json:"email"`
+	RepName  string            `firestore:"rep_name" json:"rep_name"`
+	Status   string            `firestore:"status,omitempty" json:"status"`
+	Contacts []CustomerContact `firestore:"contacts,omitempty" json:"contacts"`
+}
+
+// Active reports whether the customer was not deactivated
+func (c Customer) Active() bool {
+	return c.Status != CustomerInactive
+}
+
+// Validate checks the fields every customer needs
+func (c Customer) Validate() error {
+	rules := []rule{
+		required("code", c.Code),
+		email("email", c.Email),
+		oneOf("status", c.Status, []string{CustomerActive, CustomerInactive}),
+	}
+	seen := map[string]bool{}
+	for i, contact := range c.Contacts {
+		rules = append(rules, contact.rules("contacts", i)...)
+		address := strings.ToLower(contact.Email)
+		duplicate := seen[address]
+		seen[address] = true
+		rules = append(rules, custom(indexed("contacts", i, "email"), func() string {
+			if duplicate {
+				return "is already used by another contact"
+			}
+			return ""
+		}))
+	}
+	return validate(rules...)
+}
+
+// Recipients returns the contacts subscribed to the event. A customer without
+// contacts falls back to its email and rep name, which only ever received
+// the RMA notifications.
+func (c Customer) Recipients(event string) []CustomerContact {
+	if len(c.Contacts) == 0 {
+		if event != EventRMAReceived || c.Email == "" {
+			return nil
+		}
+		return []CustomerContact{{Name: c.RepName, Email: c.Email, Delivery: DeliveryTo, Language: LanguageES, Events: []string{EventRMAReceived}}}
+	}
+
+	var recipients []CustomerContact
+	for _, contact := range c.Contacts {
+		if contact.Subscribed(event) {
+			recipients = append(recipients, contact)
+		}
+	}
+	return recipients
+}
+
+// CustomerPatch holds the changes to a customer, nil fields are left unchanged
+type CustomerPatch struct {
+	Code     *string            `json:"code"`
+	Email    *string            `json:"email"`
+	RepName  *string            `json:"rep_name"`
+	Status   *string            `json:"status"`
+	Contacts *[]CustomerContact `json:"contacts"`
+}
+
+// Fields returns the Firestore fields set by the patch
+func (p CustomerPatch) Fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if p.Code != nil {
+		fields["code"] = *p.Code
+	}
+	if p.Email != nil {
+		fields["email"] = *p.Email
+	}
+	if p.RepName != nil {
+		fields["rep_name"] = *p.RepName
+	}
+	if p.Status != nil {
+		fields["status"] = *p.Status
+	}
+	if p.Contacts != nil {
+		fields["contacts"] = *p.Contacts
+	}
+	return fields
+}
+
+// Apply returns a copy of the customer with the patch applied
+func (p CustomerPatch) Apply(c Customer) Customer {
+	if p.Code != nil {
+		c.Code = *p.Code
+	}
+	if p.Email != nil {
+		c.Email = *p.Email
+	}
+	if p.RepName != nil {
+		c.RepName = *p.RepName
+	}
+	if p.Status != nil {
+		c.Status = *p.Status
+	}
+	if p.Contacts != nil {
+		c.Contacts = *p.Contacts
+	}
+	return c
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Deletion records who voided a movement and why. Voided movements are kept
+// in Firestore and can be restored.
+type Deletion struct {
+	DeletedAt    time.Time
+	DeletedBy    string
+	DeleteReason string
+}
@@ -15,4 +15,17 @@ type EmailData struct {
 	ProveedorRecepcion string    `firestore:"ProveedorRecepcion"`
 	Cliente            string    `firestore:"Cliente"`
 	TipoDelivery       string    `firestore:"TipoDelivery"`
+	ASN                string    `firestore:"ASN"`
+	FechaAjusteASN     time.Time `firestore:"FechaAjusteASN"`
+}
+
+// SalidaEmailData is the content of the salida dispatched notification
+type SalidaEmailData struct {
+	Cliente                string    `firestore:"Cliente"`
+	BodegaSalida           string    `firestore:"BodegaSalida"`
+	Cantidad               int       `firestore:"Cantidad"`
+	FechaSalida            time.Time `firestore:"FechaSalida"`
+	NumeroOrdenConsecutivo string    `firestore:"NumeroOrdenConsecutivo"`
+	PersonaRecoge          string    `firestore:"PersonaRecoge"`
+	EvidenciaSalida        string    `firestore:"EvidenciaSalida"`
 }
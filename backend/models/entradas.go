@@ -4,32 +4,212 @@ import (
 	"time"
 )
 
+// Delivery types of an entrada. Returns are the ones the customer is notified about.
+const TipoDeliveryRMA = "Devolución (RMA)"
+
+// TiposDelivery are the accepted delivery types, replaced at startup by
+// TIPOS_DELIVERY when it is set
+var TiposDelivery = []string{TipoDeliveryRMA, "Paquetería", "Entrega de proveedor", "Traspaso entre bodegas"}
+
 type Entradas struct {
-	TipoDelivery          string    `json:"tipo_delivery"`
-	BodegaRecepcion       string    `json:"bodega_recepcion"`
-	ProveedorRecepcion    string    `json:"proveedor_recepcion"`
-	Cliente               string    `json:"cliente"`
-	NumeroRemisionFactura string    `json:"numero_remision_factura"`
-	PersonaRecepcion      string    `json:"persona_recepcion"`
-	FechaRecepcion        time.Time `json:"fecha_recepcion"`
-	EvidenciaRecepcion    string    `json:"evidencia_recepcion"` // GCS URL or object path
-	Cantidad              int64     `json:"cantidad"`
-	Comentarios           string    `json:"comentarios"`
-	Type                  string    `json:"type"`
+	TipoDelivery          string     `json:"tipo_delivery"`
+	BodegaRecepcion       string     `json:"bodega_recepcion"`
+	ProveedorRecepcion    string     `json:"proveedor_recepcion"`
+	Cliente               string     `json:"cliente"`
+	NumeroRemisionFactura string     `json:"numero_remision_factura"`
+	PersonaRecepcion      string     `json:"persona_recepcion"`
+	FechaRecepcion        time.Time  `json:"fecha_recepcion"`
+	EvidenciaRecepcion    string     `json:"evidencia_recepcion"` // GCS URL or object path
+	Cantidad              int64      `json:"cantidad"`            // sum of the line items when there are any
+	LineItems             []LineItem `json:"line_items"`
+	Comentarios           string     `json:"comentarios"`
+	Type                  string     `json:"type"`
+	CreatedBy             string     `json:"created_by"` // UID of the authenticated user
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedBy             string     `json:"updated_by"`
+	UpdatedAt             time.Time  `json:"updated_at"`
 }
 
 type EntradasData struct {
-	BodegaRecepcion    string    `firestore:"BodegaRecepcion"`
-	Cantidad           int       `firestore:"Cantidad"`
-	Comentarios        string    `firestore:"Comentarios"`
-	EvidenciaRecepcion string    `firestore:"EvidenciaRecepcion"`
-	FechaRecepcion     time.Time `firestore:"FechaRecepcion"`
-	NumeroRemision     string    `firestore:"NumeroRemisionFactura"`
-	PersonaRecepcion   string    `firestore:"PersonaRecepcion"`
-	ProveedorRecepcion string    `firestore:"ProveedorRecepcion"`
-	Cliente            string    `firestore:"Cliente"`
-	TipoDelivery       string    `firestore:"TipoDelivery"`
-	ASN                string    `firestore:"ASN"`
-	FechaAjusteASN     time.Time `firestore:"FechaAjusteASN"`
-	Type               string    `firestore:"type"`
+	BodegaRecepcion    string     `firestore:"BodegaRecepcion"`
+	Cantidad           int        `firestore:"Cantidad"`
+	LineItems          []LineItem `firestore:"LineItems"`
+	Comentarios        string     `firestore:"Comentarios"`
+	EvidenciaRecepcion string     `firestore:"EvidenciaRecepcion"`
+	FechaRecepcion     time.Time  `firestore:"FechaRecepcion"`
+	NumeroRemision     string     `firestore:"NumeroRemisionFactura"`
+	PersonaRecepcion   string     `firestore:"PersonaRecepcion"`
+	ProveedorRecepcion string     `firestore:"ProveedorRecepcion"`
+	Cliente            string     `firestore:"Cliente"`
+	TipoDelivery       string     `firestore:"TipoDelivery"`
+	ASN                string     `firestore:"ASN"`
+	FechaAjusteASN     time.Time  `firestore:"FechaAjusteASN"`
+	Type               string     `firestore:"type"`
+	CreatedBy          string     `firestore:"CreatedBy"`
+	CreatedAt          time.Time  `firestore:"CreatedAt"`
+	UpdatedBy          string     `firestore:"UpdatedBy"`
+	UpdatedAt          time.Time  `firestore:"UpdatedAt"`
+	DeletedAt          time.Time  `firestore:"DeletedAt,omitempty"`
+	DeletedBy          string     `firestore:"DeletedBy,omitempty"`
+	DeleteReason       string     `firestore:"DeleteReason,omitempty"`
+}
+
+type EntradasDataWithID struct {
+	ID string `json:"id"`
+	EntradasData
+}
+
+// Data returns the entrada as it is stored in Firestore
+func (e Entradas) Data() EntradasData {
+	return EntradasData{
+		BodegaRecepcion:    e.BodegaRecepcion,
+		Cantidad:           int(e.Cantidad),
+		LineItems:          e.LineItems,
+		Comentarios:        e.Comentarios,
+		EvidenciaRecepcion: e.EvidenciaRecepcion,
+		FechaRecepcion:     e.FechaRecepcion,
+		NumeroRemision:     e.NumeroRemisionFactura,
+		PersonaRecepcion:   e.PersonaRecepcion,
+		ProveedorRecepcion: e.ProveedorRecepcion,
+		Cliente:            e.Cliente,
+		TipoDelivery:       e.TipoDelivery,
+		Type:               e.Type,
+		CreatedBy:          e.CreatedBy,
+		CreatedAt:          e.CreatedAt,
+		UpdatedBy:          e.UpdatedBy,
+		UpdatedAt:          e.UpdatedAt,
+	}
+}
+
+// EntradasPatch holds the corrections to an entrada, nil fields are left unchanged
+type EntradasPatch struct {
+	TipoDelivery          *string     `json:"tipo_delivery"`
+	BodegaRecepcion       *string     `json:"bodega_recepcion"`
+	ProveedorRecepcion    *string     `json:"proveedor_recepcion"`
+	Cliente               *string     `json:"cliente"`
+	NumeroRemisionFactura *string     `json:"numero_remision_factura"`
+	PersonaRecepcion      *string     `json:"persona_recepcion"`
+	FechaRecepcion        *time.Time  `json:"fecha_recepcion"`
+	Cantidad              *int64      `json:"cantidad"`
+	LineItems             *[]LineItem `json:"line_items"`
+	Comentarios           *string     `json:"comentarios"`
+	UpdatedBy             string      `json:"-"`
+	UpdatedAt             time.Time   `json:"-"`
+}
+
+// Fields returns the Firestore fields set by the patch
+func (p EntradasPatch) Fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"UpdatedBy": p.UpdatedBy,
+		"UpdatedAt": p.UpdatedAt,
+	}
+	if p.TipoDelivery != nil {
+		fields["TipoDelivery"] = *p.TipoDelivery
+	}
+	if p.BodegaRecepcion != nil {
+		fields["BodegaRecepcion"] = *p.BodegaRecepcion
+	}
+	if p.ProveedorRecepcion != nil {
+		fields["ProveedorRecepcion"] = *p.ProveedorRecepcion
+	}
+	if p.Cliente != nil {
+		fields["Cliente"] = *p.Cliente
+	}
+	if p.NumeroRemisionFactura != nil {
+		fields["NumeroRemisionFactura"] = *p.NumeroRemisionFactura
+	}
+	if p.PersonaRecepcion != nil {
+		fields["PersonaRecepcion"] = *p.PersonaRecepcion
+	}
+	if p.FechaRecepcion != nil {
+		fields["FechaRecepcion"] = *p.FechaRecepcion
+	}
+	if p.Cantidad != nil {
+		fields["Cantidad"] = *p.Cantidad
+	}
+	if p.LineItems != nil {
+		fields["LineItems"] = *p.LineItems
+	}
+	if p.Comentarios != nil {
+		fields["Comentarios"] = *p.Comentarios
+	}
+	return fields
+}
+
+// Apply returns a copy of the entrada with the patch applied
+func (p EntradasPatch) Apply(e EntradasData) EntradasData {
+	if p.TipoDelivery != nil {
+		e.TipoDelivery = *p.TipoDelivery
+	}
+	if p.BodegaRecepcion != nil {
+		e.BodegaRecepcion = *p.BodegaRecepcion
+	}
+	if p.ProveedorRecepcion != nil {
+		e.ProveedorRecepcion = *p.ProveedorRecepcion
+	}
+	if p.Cliente != nil {
+		e.Cliente = *p.Cliente
+	}
+	if p.NumeroRemisionFactura != nil {
+		e.NumeroRemision = *p.NumeroRemisionFactura
+	}
+	if p.PersonaRecepcion != nil {
+		e.PersonaRecepcion = *p.PersonaRecepcion
+	}
+	if p.FechaRecepcion != nil {
+		e.FechaRecepcion = *p.FechaRecepcion
+	}
+	if p.Cantidad != nil {
+		e.Cantidad = int(*p.Cantidad)
+	}
+	if p.LineItems != nil {
+		e.LineItems = *p.LineItems
+	}
+	if p.Comentarios != nil {
+		e.Comentarios = *p.Comentarios
+	}
+	e.UpdatedBy = p.UpdatedBy
+	e.UpdatedAt = p.UpdatedAt
+	return e
+}
+
+// Deleted reports whether the movement was voided
+func (e EntradasData) Deleted() bool {
+	return !e.DeletedAt.IsZero()
+}
+
+// WithDeletion returns a copy of the movement voided by d, or restored when d is the zero Deletion
+func (e EntradasData) WithDeletion(d Deletion) EntradasData {
+	e.DeletedAt = d.DeletedAt
+	e.DeletedBy = d.DeletedBy
+	e.DeleteReason = d.DeleteReason
+	return e
+}
+
+// Validate checks a new entrada, dates are compared with now
+func (e Entradas) Validate(now time.Time) error {
+	return e.Data().Validate(now)
+}
+
+// Validate checks an entrada as it is stored, new or corrected. Fields are
+// reported by their request names.
+func (e EntradasData) Validate(now time.Time) error {
+	return validate(
+		required("tipo_delivery", e.TipoDelivery),
+		oneOf("tipo_delivery", e.TipoDelivery, TiposDelivery),
+		required("bodega_recepcion", e.BodegaRecepcion),
+		required("proveedor_recepcion", e.ProveedorRecepcion),
+		required("cliente", e.Cliente),
+		custom("cliente", func() string {
+			if e.TipoDelivery == TipoDeliveryRMA && e.Cliente == "N/A" {
+				return "is required for returns (RMA)"
+			}
+			return ""
+		}),
+		required("numero_remision_factura", e.NumeroRemision),
+		required("persona_recepcion", e.PersonaRecepcion),
+		date("fecha_recepcion", e.FechaRecepcion, now),
+		positive("cantidad", int64(e.Cantidad)),
+		lineItems("line_items", e.LineItems),
+	)
 }
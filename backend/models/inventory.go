@@ -0,0 +1,105 @@
+package models
+
+import (
+	"net/url"
+	"sort"
+	"time"
+)
+
+// InventoryBalance is the stock of one SKU held for a cliente in a bodega,
+// kept up to date by every entrada and salida with line items
+type InventoryBalance struct {
+	Cliente   string    `json:"cliente" firestore:"cliente"`
+	Bodega    string    `json:"bodega" firestore:"bodega"`
+	SKU       string    `json:"sku" firestore:"sku"`
+	Cantidad  int64     `json:"cantidad" firestore:"cantidad"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// InventoryKey identifies an inventory balance
+type InventoryKey struct {
+	Cliente string
+	Bodega  string
+	SKU     string
+}
+
+// ID returns the document ID of the balance, each part is escaped so that it
+// cannot contain a slash
+func (k InventoryKey) ID() string {
+	return url.PathEscape(k.Cliente) + "|" + url.PathEscape(k.Bodega) + "|" + url.PathEscape(k.SKU)
+}
+
+// Less orders keys by cliente, bodega and SKU
+func (k InventoryKey) Less(o InventoryKey) bool {
+	if k.Cliente != o.Cliente {
+		return k.Cliente < o.Cliente
+	}
+	if k.Bodega != o.Bodega {
+		return k.Bodega < o.Bodega
+	}
+	return k.SKU < o.SKU
+}
+
+// SortBalances orders balances by cliente, bodega and SKU
+func SortBalances(balances []InventoryBalance) {
+	sort.Slice(balances, func(i, j int) bool {
+		a, b := balances[i], balances[j]
+		keyA := InventoryKey{Cliente: a.Cliente, Bodega: a.Bodega, SKU: a.SKU}
+		return keyA.Less(InventoryKey{Cliente: b.Cliente, Bodega: b.Bodega, SKU: b.SKU})
+	})
+}
+
+// InventoryDeltas returns the stock the entrada adds per balance, nothing
+// when it was voided
+func (e EntradasData) InventoryDeltas() map[InventoryKey]int64 {
+	deltas := map[InventoryKey]int64{}
+	if e.Deleted() {
+		return deltas
+	}
+	for _, item := range e.LineItems {
+		deltas[InventoryKey{Cliente: e.Cliente, Bodega: e.BodegaRecepcion, SKU: item.SKU}] += item.Cantidad
+	}
+	return deltas
+}
+
+// InventoryDeltas returns the stock the salida removes per balance, as
+// negative quantities, nothing when it was voided
+func (s SalidasData) InventoryDeltas() map[InventoryKey]int64 {
+	deltas := map[InventoryKey]int64{}
+	if s.Deleted() {
+		return deltas
+	}
+	for _, item := range s.LineItems {
+		deltas[InventoryKey{Cliente: s.Cliente, Bodega: s.BodegaSalida, SKU: item.SKU}] -= item.Cantidad
+	}
+	return deltas
+}
+
+// InventorySnapshot is the closing inventory of a month, computed by replaying
+// the movements dated before AsOf. The balances are stored in a subcollection.
+type InventorySnapshot struct {
+	Period    string             `json:"period" firestore:"period"` // YYYY-MM
+	AsOf      time.Time          `json:"as_of" firestore:"as_of"`   // first instant of the next month
+	Balances  []InventoryBalance `json:"balances,omitempty" firestore:"-"`
+	CreatedBy string             `json:"created_by" firestore:"created_by"`
+	CreatedAt time.Time          `json:"created_at" firestore:"created_at"`
+}
+
+// InventoryCount is one line of a physical count
+type InventoryCount struct {
+	Cliente  string `json:"cliente"`
+	Bodega   string `json:"bodega"`
+	SKU      string `json:"sku"`
+	Cantidad int64  `json:"cantidad"`
+}
+
+// ReconciliationLine compares the expected stock of a balance with its
+// physical count. Difference is counted minus expected.
+type ReconciliationLine struct {
+	Cliente    string `json:"cliente"`
+	Bodega     string `json:"bodega"`
+	SKU        string `json:"sku"`
+	Expected   int64  `json:"expected"`
+	Counted    int64  `json:"counted"`
+	Difference int64  `json:"difference"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LineItem is the SKU-level detail of an entrada or salida. Stored in
+// Firestore under the Go field names, like the movements themselves.
+type LineItem struct {
+	SKU          string `json:"sku"`
+	Descripcion  string `json:"descripcion"`
+	LoteSerie    string `json:"lote_serie"` // lot or serial number
+	Cantidad     int64  `json:"cantidad"`
+	UnidadMedida string `json:"unidad_medida"`
+	Condicion    string `json:"condicion"`
+}
+
+// ValidateLineItems checks that every item names a SKU and a unit of measure
+// and has a positive quantity
+func ValidateLineItems(items []LineItem) error {
+	for i, item := range items {
+		if strings.TrimSpace(item.SKU) == "" {
+			return fmt.Errorf("line item %d: missing sku", i+1)
+		}
+		if item.Cantidad <= 0 {
+			return fmt.Errorf("line item %d: cantidad must be positive", i+1)
+		}
+		if strings.TrimSpace(item.UnidadMedida) == "" {
+			return fmt.Errorf("line item %d: missing unidad_medida", i+1)
+		}
+	}
+	return nil
+}
+
+// LineItemsTotal sums the quantities of the items
+func LineItemsTotal(items []LineItem) int64 {
+	var total int64
+	for _, item := range items {
+		total += item.Cantidad
+	}
+	return total
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Proveedor is a carrier or supplier of the "proveedores" collection, keyed
+// by its code. Movements store the code in ProveedorRecepcion and
+// ProveedorSalida; the aliases are other spellings accepted on submit.
+type Proveedor struct {
+	Code      string    `json:"code" firestore:"code"`
+	Name      string    `json:"name" firestore:"name"`
+	Aliases   []string  `json:"aliases" firestore:"aliases"`
+	Active    bool      `json:"active" firestore:"active"`
+	CreatedBy string    `json:"created_by" firestore:"created_by"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedBy string    `json:"updated_by" firestore:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// NormalizeProveedorCode returns the canonical form of a proveedor code: trimmed and upper case
+func NormalizeProveedorCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Spellings returns the folded code, name and aliases the proveedor is known by
+func (p Proveedor) Spellings() []string {
+	spellings := []string{FoldSpelling(p.Code), FoldSpelling(p.Name)}
+	for _, alias := range p.Aliases {
+		spellings = append(spellings, FoldSpelling(alias))
+	}
+	return spellings
+}
+
+// Matches reports whether value is the code, name or an alias of the
+// proveedor, ignoring case and extra spaces
+func (p Proveedor) Matches(value string) bool {
+	value = FoldSpelling(value)
+	if value == "" {
+		return false
+	}
+	for _, spelling := range p.Spellings() {
+		if spelling == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the fields every proveedor needs, reporting every bad field
+func (p Proveedor) Validate() error {
+	rules := []rule{
+		required("code", p.Code),
+		code("code", p.Code),
+		required("name", p.Name),
+	}
+	for i, alias := range p.Aliases {
+		rules = append(rules, custom(fmt.Sprintf("aliases[%d]", i), func() string {
+			if strings.TrimSpace(alias) == "" {
+				return "cannot be empty"
+			}
+			return ""
+		}))
+	}
+	return validate(rules...)
+}
+
+// ProveedorPatch holds the changes to a proveedor, nil fields are left unchanged.
+// The code cannot change since movements reference it.
+type ProveedorPatch struct {
+	Name      *string   `json:"name"`
+	Aliases   *[]string `json:"aliases"` // replaces every alias
+	Active    *bool     `json:"active"`
+	UpdatedBy string    `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// Fields returns the Firestore fields set by the patch
+func (p ProveedorPatch) Fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"updated_by": p.UpdatedBy,
+		"updated_at": p.UpdatedAt,
+	}
+	if p.Name != nil {
+		fields["name"] = *p.Name
+	}
+	if p.Aliases != nil {
+		fields["aliases"] = *p.Aliases
+	}
+	if p.Active != nil {
+		fields["active"] = *p.Active
+	}
+	return fields
+}
+
+// Apply returns a copy of the proveedor with the patch applied
+func (p ProveedorPatch) Apply(v Proveedor) Proveedor {
+	if p.Name != nil {
+		v.Name = *p.Name
+	}
+	if p.Aliases != nil {
+		v.Aliases = *p.Aliases
+	}
+	if p.Active != nil {
+		v.Active = *p.Active
+	}
+	v.UpdatedBy = p.UpdatedBy
+	v.UpdatedAt = p.UpdatedAt
+	return v
+}
@@ -5,29 +5,197 @@ import (
 )
 
 type Salidas struct {
-	BodegaSalida           string    `json:"bodega_salida"`
-	ProveedorSalida        string    `json:"proveedor_salida"`
-	Cliente                string    `json:"cliente"`
-	NumeroOrdenConsecutivo string    `json:"numero_orden_consecutivo"`
-	PersonaEntrega         string    `json:"persona_entrega"`
-	PersonaRecoge          string    `json:"persona_recoge"`
-	FirmaPersonaRecoge     string    `json:"firma_persona_recoge"` // GCS URL or object path
-	FechaSalida            time.Time `json:"fecha_salida"`
-	EvidenciaSalida        string    `json:"evidencia_salida"` // GCS URL or object path
-	Comentarios            string    `json:"comentarios"`
-	Type                   string    `json:"type"`
+	BodegaSalida           string     `json:"bodega_salida"`
+	ProveedorSalida        string     `json:"proveedor_salida"`
+	Cliente                string     `json:"cliente"`
+	NumeroOrdenConsecutivo string     `json:"numero_orden_consecutivo"`
+	PersonaEntrega         string     `json:"persona_entrega"`
+	PersonaRecoge          string     `json:"persona_recoge"`
+	FirmaPersonaRecoge     string     `json:"firma_persona_recoge"` // GCS URL or object path
+	FechaSalida            time.Time  `json:"fecha_salida"`
+	EvidenciaSalida        string     `json:"evidencia_salida"` // GCS URL or object path
+	Cantidad               int64      `json:"cantidad"`         // sum of the line items
+	LineItems              []LineItem `json:"line_items"`
+	Comentarios            string     `json:"comentarios"`
+	Type                   string     `json:"type"`
+	StockOverrideBy        string     `json:"stock_override_by"` // UID of the supervisor who allowed negative stock
+	CreatedBy              string     `json:"created_by"`        // UID of the authenticated user
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedBy              string     `json:"updated_by"`
+	UpdatedAt              time.Time  `json:"updated_at"`
 }
 
 type SalidasData struct {
-	BodegaSalida           string    `firestore:"BodegaSalida"`
-	ProveedorSalida        string    `firestore:"ProveedorSalida"`
-	Cliente                string    `firestore:"Cliente"`
-	NumeroOrdenConsecutivo string    `firestore:"NumeroOrdenConsecutivo"`
-	PersonaEntrega         string    `firestore:"PersonaEntrega"`
-	PersonaRecoge          string    `firestore:"PersonaRecoge"`
-	FirmaPersonaRecoge     string    `firestore:"FirmaPersonaRecoge"` // GCS URL or object path
-	FechaSalida            time.Time `firestore:"FechaSalida"`
-	EvidenciaSalida        string    `firestore:"EvidenciaSalida"` // GCS URL or object path
-	Comentarios            string    `firestore:"Comentarios"`
-	Type                   string    `firestore:"type"`
+	BodegaSalida           string     `firestore:"BodegaSalida"`
+	ProveedorSalida        string     `firestore:"ProveedorSalida"`
+	Cliente                string     `firestore:"Cliente"`
+	NumeroOrdenConsecutivo string     `firestore:"NumeroOrdenConsecutivo"`
+	PersonaEntrega         string     `firestore:"PersonaEntrega"`
+	PersonaRecoge          string     `firestore:"PersonaRecoge"`
+	FirmaPersonaRecoge     string     `firestore:"FirmaPersonaRecoge"` // GCS URL or object path
+	FechaSalida            time.Time  `firestore:"FechaSalida"`
+	EvidenciaSalida        string     `firestore:"EvidenciaSalida"` // GCS URL or object path
+	Cantidad               int        `firestore:"Cantidad"`
+	LineItems              []LineItem `firestore:"LineItems"`
+	Comentarios            string     `firestore:"Comentarios"`
+	Type                   string     `firestore:"type"`
+	StockOverrideBy        string     `firestore:"StockOverrideBy,omitempty"`
+	CreatedBy              string     `firestore:"CreatedBy"`
+	CreatedAt              time.Time  `firestore:"CreatedAt"`
+	UpdatedBy              string     `firestore:"UpdatedBy"`
+	UpdatedAt              time.Time  `firestore:"UpdatedAt"`
+	DeletedAt              time.Time  `firestore:"DeletedAt,omitempty"`
+	DeletedBy              string     `firestore:"DeletedBy,omitempty"`
+	DeleteReason           string     `firestore:"DeleteReason,omitempty"`
+}
+
+type SalidasDataWithID struct {
+	ID string `json:"id"`
+	SalidasData
+}
+
+// Data returns the salida as it is stored in Firestore
+func (s Salidas) Data() SalidasData {
+	return SalidasData{
+		BodegaSalida:           s.BodegaSalida,
+		ProveedorSalida:        s.ProveedorSalida,
+		Cliente:                s.Cliente,
+		NumeroOrdenConsecutivo: s.NumeroOrdenConsecutivo,
+		PersonaEntrega:         s.PersonaEntrega,
+		PersonaRecoge:          s.PersonaRecoge,
+		FirmaPersonaRecoge:     s.FirmaPersonaRecoge,
+		FechaSalida:            s.FechaSalida,
+		EvidenciaSalida:        s.EvidenciaSalida,
+		Cantidad:               int(s.Cantidad),
+		LineItems:              s.LineItems,
+		Comentarios:            s.Comentarios,
+		Type:                   s.Type,
+		StockOverrideBy:        s.StockOverrideBy,
+		CreatedBy:              s.CreatedBy,
+		CreatedAt:              s.CreatedAt,
+		UpdatedBy:              s.UpdatedBy,
+		UpdatedAt:              s.UpdatedAt,
+	}
+}
+
+// SalidasPatch holds the corrections to a salida, nil fields are left unchanged
+type SalidasPatch struct {
+	BodegaSalida           *string     `json:"bodega_salida"`
+	ProveedorSalida        *string     `json:"proveedor_salida"`
+	Cliente                *string     `json:"cliente"`
+	NumeroOrdenConsecutivo *string     `json:"numero_orden_consecutivo"`
+	PersonaEntrega         *string     `json:"persona_entrega"`
+	PersonaRecoge          *string     `json:"persona_recoge"`
+	FechaSalida            *time.Time  `json:"fecha_salida"`
+	Comentarios            *string     `json:"comentarios"`
+	LineItems              *[]LineItem `json:"line_items"` // also resets Cantidad to their total
+	UpdatedBy              string      `json:"-"`
+	UpdatedAt              time.Time   `json:"-"`
+}
+
+// Fields returns the Firestore fields set by the patch
+func (p SalidasPatch) Fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"UpdatedBy": p.UpdatedBy,
+		"UpdatedAt": p.UpdatedAt,
+	}
+	if p.BodegaSalida != nil {
+		fields["BodegaSalida"] = *p.BodegaSalida
+	}
+	if p.ProveedorSalida != nil {
+		fields["ProveedorSalida"] = *p.ProveedorSalida
+	}
+	if p.Cliente != nil {
+		fields["Cliente"] = *p.Cliente
+	}
+	if p.NumeroOrdenConsecutivo != nil {
+		fields["NumeroOrdenConsecutivo"] = *p.NumeroOrdenConsecutivo
+	}
+	if p.PersonaEntrega != nil {
+		fields["PersonaEntrega"] = *p.PersonaEntrega
+	}
+	if p.PersonaRecoge != nil {
+		fields["PersonaRecoge"] = *p.PersonaRecoge
+	}
+	if p.FechaSalida != nil {
+		fields["FechaSalida"] = *p.FechaSalida
+	}
+	if p.Comentarios != nil {
+		fields["Comentarios"] = *p.Comentarios
+	}
+	if p.LineItems != nil {
+		fields["LineItems"] = *p.LineItems
+		fields["Cantidad"] = LineItemsTotal(*p.LineItems)
+	}
+	return fields
+}
+
+// Apply returns a copy of the salida with the patch applied
+func (p SalidasPatch) Apply(s SalidasData) SalidasData {
+	if p.BodegaSalida != nil {
+		s.BodegaSalida = *p.BodegaSalida
+	}
+	if p.ProveedorSalida != nil {
+		s.ProveedorSalida = *p.ProveedorSalida
+	}
+	if p.Cliente != nil {
+		s.Cliente = *p.Cliente
+	}
+	if p.NumeroOrdenConsecutivo != nil {
+		s.NumeroOrdenConsecutivo = *p.NumeroOrdenConsecutivo
+	}
+	if p.PersonaEntrega != nil {
+		s.PersonaEntrega = *p.PersonaEntrega
+	}
+	if p.PersonaRecoge != nil {
+		s.PersonaRecoge = *p.PersonaRecoge
+	}
+	if p.FechaSalida != nil {
+		s.FechaSalida = *p.FechaSalida
+	}
+	if p.Comentarios != nil {
+		s.Comentarios = *p.Comentarios
+	}
+	if p.LineItems != nil {
+		s.LineItems = *p.LineItems
+		s.Cantidad = int(LineItemsTotal(*p.LineItems))
+	}
+	s.UpdatedBy = p.UpdatedBy
+	s.UpdatedAt = p.UpdatedAt
+	return s
+}
+
+// Deleted reports whether the movement was voided
+func (s SalidasData) Deleted() bool {
+	return !s.DeletedAt.IsZero()
+}
+
+// WithDeletion returns a copy of the movement voided by d, or restored when d is the zero Deletion
+func (s SalidasData) WithDeletion(d Deletion) SalidasData {
+	s.DeletedAt = d.DeletedAt
+	s.DeletedBy = d.DeletedBy
+	s.DeleteReason = d.DeleteReason
+	return s
+}
+
+// Validate checks a new salida, dates are compared with now
+func (s Salidas) Validate(now time.Time) error {
+	return s.Data().Validate(now)
+}
+
+// Validate checks a salida as it is stored, new or corrected. Fields are
+// reported by their request names. Cantidad is the total of the line items,
+// so a salida without items has none.
+func (s SalidasData) Validate(now time.Time) error {
+	return validate(
+		required("bodega_salida", s.BodegaSalida),
+		required("proveedor_salida", s.ProveedorSalida),
+		required("cliente", s.Cliente),
+		required("numero_orden_consecutivo", s.NumeroOrdenConsecutivo),
+		required("persona_entrega", s.PersonaEntrega),
+		required("persona_recoge", s.PersonaRecoge),
+		date("fecha_salida", s.FechaSalida, now),
+		nonNegative("cantidad", int64(s.Cantidad)),
+		lineItems("line_items", s.LineItems),
+	)
 }
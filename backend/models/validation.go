@@ -0,0 +1,182 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// MaxFutureSkew is how far ahead of the server clock a movement date may be
+const MaxFutureSkew = 24 * time.Hour
+
+// FieldError is a field of a request that failed validation, named as in the
+// request (form value or JSON key)
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field of a request that failed validation, at
+// most one error per field
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Add records an error for the field, unless it already has one
+func (e *ValidationError) Add(field, message string) {
+	for _, f := range e.Fields {
+		if f.Field == field {
+			return
+		}
+	}
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// Merge adds the fields of a ValidationError. Any other error is recorded
+// under field.
+func (e *ValidationError) Merge(field string, err error) {
+	if err == nil {
+		return
+	}
+	if v, ok := err.(*ValidationError); ok {
+		for _, f := range v.Fields {
+			e.Add(f.Field, f.Message)
+		}
+		return
+	}
+	e.Add(field, err.Error())
+}
+
+// Err returns the error, nil when no field failed
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// A rule checks one field, the returned message is empty when valid
+type rule struct {
+	field string
+	check func() string
+}
+
+// Runs every rule and collects the failures
+func validate(rules ...rule) error {
+	var errs ValidationError
+	for _, r := range rules {
+		if msg := r.check(); msg != "" {
+			errs.Add(r.field, msg)
+		}
+	}
+	return errs.Err()
+}
+
+func required(field, value string) rule {
+	return rule{field, func() string {
+		if strings.TrimSpace(value) == "" {
+			return "is required"
+		}
+		return ""
+	}}
+}
+
+// Empty values pass, required reports them
+func oneOf(field, value string, allowed []string) rule {
+	return rule{field, func() string {
+		if value == "" {
+			return ""
+		}
+		for _, a := range allowed {
+			if value == a {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(allowed, ", ")
+	}}
+}
+
+func positive(field string, n int64) rule {
+	return rule{field, func() string {
+		if n <= 0 {
+			return "must be positive"
+		}
+		return ""
+	}}
+}
+
+func nonNegative(field string, n int64) rule {
+	return rule{field, func() string {
+		if n < 0 {
+			return "must not be negative"
+		}
+		return ""
+	}}
+}
+
+// Empty values pass, required reports them. Display names are rejected so that
+// the stored value is the bare address.
+func email(field, value string) rule {
+	return rule{field, func() string {
+		if value == "" {
+			return ""
+		}
+		addr, err := mail.ParseAddress(value)
+		if err != nil || addr.Address != value {
+			return "must be a valid email address"
+		}
+		return ""
+	}}
+}
+
+// A date that must be set and not later than MaxFutureSkew after now
+func date(field string, t, now time.Time) rule {
+	return rule{field, func() string {
+		if t.IsZero() {
+			return "is required"
+		}
+		if t.After(now.Add(MaxFutureSkew)) {
+			return "must not be in the future"
+		}
+		return ""
+	}}
+}
+
+// A catalog code, the key of its document. Empty values pass, required
+// reports them.
+func code(field, value string) rule {
+	return rule{field, func() string {
+		if strings.ContainsAny(value, "/ ") {
+			return "cannot contain spaces or slashes"
+		}
+		return ""
+	}}
+}
+
+func lineItems(field string, items []LineItem) rule {
+	return rule{field, func() string {
+		if err := ValidateLineItems(items); err != nil {
+			return err.Error()
+		}
+		return ""
+	}}
+}
+
+// A rule whose message is decided by the caller
+func custom(field string, check func() string) rule {
+	return rule{field, check}
+}
+
+// Prefixes the fields of nested values, e.g. contacts[0].email
+func indexed(field string, i int, sub string) string {
+	return fmt.Sprintf("%s[%d].%s", field, i, sub)
+}
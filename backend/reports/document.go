@@ -0,0 +1,131 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/go-pdf/fpdf"
+)
+
+const (
+	// Largest box an embedded image is scaled into, in mm
+	maxImageWidth  = 120.0
+	maxImageHeight = 75.0
+)
+
+// Image is a photo embedded in a PDF document. Missing or unreadable images
+// are replaced by a note so that the document can still be issued.
+type Image struct {
+	Label string
+	Data  []byte
+}
+
+// One labelled row of a PDF document
+type field struct {
+	label string
+	value string
+}
+
+// Returns the fpdf image type of the data, empty when it cannot be embedded
+func imageType(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return "JPG"
+	case "image/png":
+		return "PNG"
+	case "image/gif":
+		return "GIF"
+	}
+	return ""
+}
+
+// Renders a single-document PDF: a title, a table of fields, the line items if
+// any and the images below them. Text goes through the cp1252 translator of the core fonts.
+func renderDocument(title, folio string, fields []field, items []models.LineItem, images []Image, generatedAt time.Time) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(title, true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, tr("Generado el "+generatedAt.UTC().Format("2006-01-02 15:04 MST")), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, tr(title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, tr("Folio: "+folio), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	for _, f := range fields {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(55, 8, tr(f.label), "1", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 8, tr(f.value), "1", 1, "L", false, 0, "")
+	}
+
+	if len(items) > 0 {
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 6, tr("Partidas"), "", 1, "L", false, 0, "")
+		widths := []float64{30, 60, 30, 20, 20, 30}
+		header := []string{"SKU", "Descripción", "Lote / serie", "Cantidad", "Unidad", "Condición"}
+		for i, h := range header {
+			pdf.CellFormat(widths[i], 7, tr(h), "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 9)
+		for _, item := range items {
+			row := []string{item.SKU, item.Descripcion, item.LoteSerie, strconv.FormatInt(item.Cantidad, 10), item.UnidadMedida, item.Condicion}
+			for i, v := range row {
+				pdf.CellFormat(widths[i], 7, tr(v), "1", 0, "L", false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+
+	for i, img := range images {
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 6, tr(img.Label), "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+
+		kind := imageType(img.Data)
+		if kind == "" {
+			pdf.CellFormat(0, 6, tr("Imagen no disponible"), "", 1, "L", false, 0, "")
+			continue
+		}
+		name := fmt.Sprintf("image%d", i)
+		options := fpdf.ImageOptions{ImageType: kind}
+		info := pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(img.Data))
+		if pdf.Err() {
+			// A corrupt image must not prevent the document from being issued
+			pdf.ClearError()
+			pdf.CellFormat(0, 6, tr("Imagen no disponible"), "", 1, "L", false, 0, "")
+			continue
+		}
+
+		w, h := info.Width(), info.Height()
+		scale := min(maxImageWidth/w, maxImageHeight/h)
+		pdf.ImageOptions(name, -1, -1, w*scale, h*scale, true, options, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Formats a timestamp for a document, zero times are left empty
+func documentTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
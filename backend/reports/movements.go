@@ -0,0 +1,236 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	entradasSheet = "Entradas"
+	salidasSheet  = "Salidas"
+	dateFormat    = "2006-01-02 15:04"
+)
+
+var (
+	entradasHeader = []interface{}{
+		"Fecha recepción", "Bodega", "Proveedor", "Tipo delivery", "Remisión / factura",
+		"Persona recepción", "Cantidad", "ASN", "Comentarios", "Evidencia",
+	}
+	salidasHeader = []interface{}{
+		"Fecha salida", "Bodega", "Proveedor", "Orden consecutivo", "Persona entrega",
+		"Persona recoge", "Cantidad", "Comentarios", "Evidencia",
+	}
+)
+
+// MovementsReport holds the movements of one customer over a period
+type MovementsReport struct {
+	Cliente  string
+	From     time.Time
+	To       time.Time // exclusive
+	Entradas []models.EntradasDataWithID
+	Salidas  []models.SalidasDataWithID
+}
+
+// Filename returns the suggested name of the workbook
+func (r MovementsReport) Filename() string {
+	cliente := strings.Map(func(c rune) rune {
+		if c == ' ' || c == '/' || c == '\\' {
+			return '_'
+		}
+		return c
+	}, r.Cliente)
+	// A whole month is named by the month, any other period by its first and last day
+	period := r.From.Format("2006-01")
+	if r.From.Day() != 1 || !r.To.Equal(r.From.AddDate(0, 1, 0)) {
+		period = r.From.Format("2006-01-02") + "_" + r.To.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	return fmt.Sprintf("movimientos_%s_%s.xlsx", cliente, period)
+}
+
+// Workbook builds the XLSX report: one sheet per movement type with a row per
+// movement, followed by the totals per bodega. Evidence URLs are hyperlinks.
+func (r MovementsReport) Workbook() (*excelize.File, error) {
+	f := excelize.NewFile()
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	linkStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "0563C1", Underline: "single"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	styles := sheetStyles{header: headerStyle, link: linkStyle}
+
+	// The default sheet is renamed rather than left empty
+	if err := f.SetSheetName("Sheet1", entradasSheet); err != nil {
+		return nil, err
+	}
+	if err := r.writeEntradas(f, styles); err != nil {
+		return nil, err
+	}
+	if _, err := f.NewSheet(salidasSheet); err != nil {
+		return nil, err
+	}
+	if err := r.writeSalidas(f, styles); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type sheetStyles struct {
+	header int
+	link   int
+}
+
+func (r MovementsReport) writeEntradas(f *excelize.File, styles sheetStyles) error {
+	row, err := r.writeTitle(f, entradasSheet, styles, entradasHeader)
+	if err != nil {
+		return err
+	}
+	firstRow := row
+
+	totals := map[string]int{}
+	for _, e := range r.Entradas {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		values := []interface{}{
+			e.FechaRecepcion.Format(dateFormat), e.BodegaRecepcion, e.ProveedorRecepcion, e.TipoDelivery,
+			e.NumeroRemision, e.PersonaRecepcion, e.Cantidad, e.ASN, e.Comentarios, e.EvidenciaRecepcion,
+		}
+		if err := f.SetSheetRow(entradasSheet, cell, &values); err != nil {
+			return err
+		}
+		if err := setLink(f, entradasSheet, len(values), row, e.EvidenciaRecepcion, styles.link); err != nil {
+			return err
+		}
+		totals[e.BodegaRecepcion] += e.Cantidad
+		row++
+	}
+	if err := finishTable(f, entradasSheet, len(entradasHeader), firstRow-1, row-1); err != nil {
+		return err
+	}
+	return writeTotals(f, entradasSheet, row+1, styles, "Cantidad", totals)
+}
+
+func (r MovementsReport) writeSalidas(f *excelize.File, styles sheetStyles) error {
+	row, err := r.writeTitle(f, salidasSheet, styles, salidasHeader)
+	if err != nil {
+		return err
+	}
+	firstRow := row
+
+	totals := map[string]int{}
+	for _, s := range r.Salidas {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		values := []interface{}{
+			s.FechaSalida.Format(dateFormat), s.BodegaSalida, s.ProveedorSalida, s.NumeroOrdenConsecutivo,
+			s.PersonaEntrega, s.PersonaRecoge, s.Cantidad, s.Comentarios, s.EvidenciaSalida,
+		}
+		if err := f.SetSheetRow(salidasSheet, cell, &values); err != nil {
+			return err
+		}
+		if err := setLink(f, salidasSheet, len(values), row, s.EvidenciaSalida, styles.link); err != nil {
+			return err
+		}
+		totals[s.BodegaSalida] += s.Cantidad
+		row++
+	}
+	if err := finishTable(f, salidasSheet, len(salidasHeader), firstRow-1, row-1); err != nil {
+		return err
+	}
+	return writeTotals(f, salidasSheet, row+1, styles, "Cantidad", totals)
+}
+
+// Writes the customer and period on top of a sheet followed by the column
+// header. It returns the first data row.
+func (r MovementsReport) writeTitle(f *excelize.File, sheet string, styles sheetStyles, header []interface{}) (int, error) {
+	period := fmt.Sprintf("%s a %s", r.From.Format("2006-01-02"), r.To.AddDate(0, 0, -1).Format("2006-01-02"))
+	if err := f.SetSheetRow(sheet, "A1", &[]interface{}{"Cliente", r.Cliente}); err != nil {
+		return 0, err
+	}
+	if err := f.SetSheetRow(sheet, "A2", &[]interface{}{"Periodo", period}); err != nil {
+		return 0, err
+	}
+	if err := f.SetSheetRow(sheet, "A4", &header); err != nil {
+		return 0, err
+	}
+	lastCol, _ := excelize.ColumnNumberToName(len(header))
+	if err := f.SetCellStyle(sheet, "A4", lastCol+"4", styles.header); err != nil {
+		return 0, err
+	}
+	return 5, nil
+}
+
+// Freezes the header, adds filters and widens the columns of a data table
+func finishTable(f *excelize.File, sheet string, cols, headerRow, lastRow int) error {
+	lastCol, _ := excelize.ColumnNumberToName(cols)
+	if err := f.SetColWidth(sheet, "A", lastCol, 20); err != nil {
+		return err
+	}
+	if err := f.SetPanes(sheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      headerRow,
+		TopLeftCell: fmt.Sprintf("A%d", headerRow+1),
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+	if lastRow <= headerRow {
+		return nil
+	}
+	return f.AutoFilter(sheet, fmt.Sprintf("A%d:%s%d", headerRow, lastCol, lastRow), nil)
+}
+
+// Makes the evidence cell a hyperlink when it holds a URL
+func setLink(f *excelize.File, sheet string, col, row int, url string, style int) error {
+	if !strings.HasPrefix(url, "http") {
+		return nil
+	}
+	cell, _ := excelize.CoordinatesToCellName(col, row)
+	if err := f.SetCellHyperLink(sheet, cell, url, "External"); err != nil {
+		return err
+	}
+	return f.SetCellStyle(sheet, cell, cell, style)
+}
+
+// Writes the totals per bodega, sorted by bodega, and the grand total
+func writeTotals(f *excelize.File, sheet string, row int, styles sheetStyles, label string, totals map[string]int) error {
+	bodegas := make([]string, 0, len(totals))
+	for bodega := range totals {
+		bodegas = append(bodegas, bodega)
+	}
+	sort.Strings(bodegas)
+
+	cell := fmt.Sprintf("A%d", row)
+	if err := f.SetSheetRow(sheet, cell, &[]interface{}{"Bodega", label}); err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, cell, fmt.Sprintf("B%d", row), styles.header); err != nil {
+		return err
+	}
+
+	var total int
+	for _, bodega := range bodegas {
+		row++
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &[]interface{}{bodega, totals[bodega]}); err != nil {
+			return err
+		}
+		total += totals[bodega]
+	}
+	row++
+	cell = fmt.Sprintf("A%d", row)
+	if err := f.SetSheetRow(sheet, cell, &[]interface{}{"Total", total}); err != nil {
+		return err
+	}
+	return f.SetCellStyle(sheet, cell, fmt.Sprintf("B%d", row), styles.header)
+}
@@ -0,0 +1,54 @@
+package reports
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+)
+
+// SalidaReceipt renders the delivery receipt of a salida, with the signature
+// of the person picking it up and the evidence photo
+func SalidaReceipt(id string, s models.SalidasData, signature, evidence []byte) ([]byte, error) {
+	fields := []field{
+		{"Cliente", s.Cliente},
+		{"Bodega", s.BodegaSalida},
+		{"Proveedor", s.ProveedorSalida},
+		{"Orden consecutivo", s.NumeroOrdenConsecutivo},
+		{"Cantidad", strconv.Itoa(s.Cantidad)},
+		{"Entrega", s.PersonaEntrega},
+		{"Recoge", s.PersonaRecoge},
+		{"Fecha de salida", documentTime(s.FechaSalida)},
+		{"Comentarios", s.Comentarios},
+	}
+	images := []Image{
+		{Label: "Firma de quien recoge", Data: signature},
+		{Label: "Evidencia de salida", Data: evidence},
+	}
+	return renderDocument("Recibo de salida", id, fields, s.LineItems, images, time.Now())
+}
+
+// EntradaCertificate renders the "acuse de recepción" of an entrada with its
+// evidence photo
+func EntradaCertificate(id string, e models.EntradasData, evidence []byte) ([]byte, error) {
+	asn := e.ASN
+	if asn == "" {
+		asn = "Sin asignar"
+	}
+	fields := []field{
+		{"Cliente", e.Cliente},
+		{"Bodega", e.BodegaRecepcion},
+		{"Proveedor", e.ProveedorRecepcion},
+		{"Tipo de entrega", e.TipoDelivery},
+		{"Remisión / factura", e.NumeroRemision},
+		{"Cantidad", strconv.Itoa(e.Cantidad)},
+		{"ASN", asn},
+		{"Recibió", e.PersonaRecepcion},
+		{"Fecha de recepción", documentTime(e.FechaRecepcion)},
+		{"Comentarios", e.Comentarios},
+	}
+	images := []Image{
+		{Label: "Evidencia de recepción", Data: evidence},
+	}
+	return renderDocument("Acuse de recepción", id, fields, e.LineItems, images, time.Now())
+}
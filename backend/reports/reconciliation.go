@@ -0,0 +1,100 @@
+package reports
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const reconciliationSheet = "Conciliación"
+
+var reconciliationHeader = []interface{}{"Cliente", "Bodega", "SKU", "Esperado", "Contado", "Diferencia"}
+
+// ReconciliationReport compares the expected inventory at a cut-off with a
+// physical count
+type ReconciliationReport struct {
+	AsOf   time.Time // the expected stock excludes the movements from this instant on
+	Source string    // where the expected stock comes from, e.g. the snapshot period
+	Lines  []models.ReconciliationLine
+}
+
+// Filename returns the suggested name of the workbook
+func (r ReconciliationReport) Filename() string {
+	return fmt.Sprintf("conciliacion_%s.xlsx", r.AsOf.Format("2006-01-02"))
+}
+
+// Workbook builds the XLSX report: a row per balance with the expected and
+// counted quantities, differences highlighted, followed by a summary
+func (r ReconciliationReport) Workbook() (*excelize.File, error) {
+	f := excelize.NewFile()
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	differenceStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true, Color: "C00000"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.SetSheetName("Sheet1", reconciliationSheet); err != nil {
+		return nil, err
+	}
+	sheet := reconciliationSheet
+	if err := f.SetSheetRow(sheet, "A1", &[]interface{}{"Corte", r.AsOf.Format(dateFormat)}); err != nil {
+		return nil, err
+	}
+	if err := f.SetSheetRow(sheet, "A2", &[]interface{}{"Base", r.Source}); err != nil {
+		return nil, err
+	}
+	if err := f.SetSheetRow(sheet, "A4", &reconciliationHeader); err != nil {
+		return nil, err
+	}
+	if err := f.SetCellStyle(sheet, "A4", "F4", headerStyle); err != nil {
+		return nil, err
+	}
+
+	row := 5
+	var differences int
+	for _, line := range r.Lines {
+		values := []interface{}{line.Cliente, line.Bodega, line.SKU, line.Expected, line.Counted, line.Difference}
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &values); err != nil {
+			return nil, err
+		}
+		if line.Difference != 0 {
+			differences++
+			cell := fmt.Sprintf("F%d", row)
+			if err := f.SetCellStyle(sheet, cell, cell, differenceStyle); err != nil {
+				return nil, err
+			}
+		}
+		row++
+	}
+	if err := finishTable(f, sheet, len(reconciliationHeader), 4, row-1); err != nil {
+		return nil, err
+	}
+
+	row++
+	summary := [][]interface{}{
+		{"Partidas", len(r.Lines)},
+		{"Con diferencia", differences},
+	}
+	for _, values := range summary {
+		cell := fmt.Sprintf("A%d", row)
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			return nil, err
+		}
+		if err := f.SetCellStyle(sheet, cell, cell, headerStyle); err != nil {
+			return nil, err
+		}
+		row++
+	}
+	return f, nil
+}
@@ -1,22 +1,92 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/clopezbyte/app-entradas-salidas/handlers"
+	"github.com/clopezbyte/app-entradas-salidas/middleware"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRouter() *mux.Router {
+// Role sets used by the protected routes
+var (
+	staff    = []string{middleware.RoleOperator, middleware.RoleSupervisor, middleware.RoleAdmin}
+	readers  = []string{middleware.RoleOperator, middleware.RoleSupervisor, middleware.RoleAdmin, middleware.RoleCustomerReadOnly}
+	managers = []string{middleware.RoleSupervisor, middleware.RoleAdmin}
+	admins   = []string{middleware.RoleAdmin}
+)
+
+// Wraps a handler so that only principals holding one of the roles reach it
+func allow(fn http.HandlerFunc, roles []string) http.Handler {
+	return middleware.RequireRoles(roles...)(fn)
+}
+
+func SetupRouter(h *handlers.Handler) *mux.Router {
 	r := mux.NewRouter()
+
+	// Public routes, no Firebase token required
 	r.HandleFunc("/health", handlers.HealthCheck).Methods("GET")
-	r.HandleFunc("/entradas", handlers.HandleEntradasSubmit).Methods("POST")
-	r.HandleFunc("/entradas-data", handlers.HandleProvideEntradasData).Methods("POST")
-	r.HandleFunc("/salidas", handlers.HandleSalidasSubmit).Methods("POST")
-	r.HandleFunc("/salidas-data", handlers.HandleProvideSalidasData).Methods("POST")
-	r.HandleFunc("/query-entrada", handlers.QueryEntrada).Methods("POST")
-	r.HandleFunc("/update-asn", handlers.HandleASNSubmit).Methods("POST")
-	r.HandleFunc("/get-customers", handlers.HandleProvideCustomers).Methods("GET")
-	r.HandleFunc("/create-customer", handlers.HandleCreateCustomer).Methods("POST")
-	r.HandleFunc("/rma-query", handlers.HandleRmaQuery).Methods("POST")
+	r.HandleFunc("/rma-query", h.HandleRmaQuery).Methods("POST") // API key checked by the handler
+
+	// Protected routes, the ID token is verified once by the middleware
+	// and each route declares the roles allowed to call it
+	protected := r.NewRoute().Subrouter()
+	protected.Use(middleware.Authenticate(h.Verifier))
+
+	// Resource routes
+	v1 := protected.PathPrefix("/v1").Subrouter()
+	v1.Handle("/entradas", allow(h.HandleListEntradas, readers)).Methods("GET")
+	v1.Handle("/entradas", allow(h.HandleEntradasSubmit, staff)).Methods("POST")
+	v1.Handle("/entradas/export", allow(h.HandleExportEntradas, readers)).Methods("GET")
+	v1.Handle("/entradas/{id}", allow(h.HandleGetEntrada, readers)).Methods("GET")
+	v1.Handle("/entradas/{id}", allow(h.HandlePatchEntrada, managers)).Methods("PATCH")
+	v1.Handle("/entradas/{id}", allow(h.HandleDeleteEntrada, managers)).Methods("DELETE")
+	v1.Handle("/entradas/{id}/restore", allow(h.HandleRestoreEntrada, managers)).Methods("POST")
+	v1.Handle("/entradas/{id}/asn-history", allow(h.HandleASNHistory, staff)).Methods("GET")
+	v1.Handle("/entradas/{id}/certificate.pdf", allow(h.HandleEntradaCertificate, readers)).Methods("GET")
+	v1.Handle("/salidas", allow(h.HandleListSalidas, readers)).Methods("GET")
+	v1.Handle("/salidas", allow(h.HandleSalidasSubmit, staff)).Methods("POST")
+	v1.Handle("/salidas/export", allow(h.HandleExportSalidas, readers)).Methods("GET")
+	v1.Handle("/salidas/{id}", allow(h.HandleGetSalida, readers)).Methods("GET")
+	v1.Handle("/salidas/{id}", allow(h.HandlePatchSalida, managers)).Methods("PATCH")
+	v1.Handle("/salidas/{id}", allow(h.HandleDeleteSalida, managers)).Methods("DELETE")
+	v1.Handle("/salidas/{id}/restore", allow(h.HandleRestoreSalida, managers)).Methods("POST")
+	v1.Handle("/salidas/{id}/receipt.pdf", allow(h.HandleSalidaReceipt, readers)).Methods("GET")
+	v1.Handle("/customers", allow(h.HandleListCustomers, staff)).Methods("GET")
+	v1.Handle("/customers", allow(h.HandleCreateCustomer, admins)).Methods("POST")
+	v1.Handle("/customers/{id}", allow(h.HandleGetCustomer, staff)).Methods("GET")
+	v1.Handle("/customers/{id}", allow(h.HandlePutCustomer, admins)).Methods("PUT")
+	v1.Handle("/customers/{id}", allow(h.HandlePatchCustomer, admins)).Methods("PATCH")
+	v1.Handle("/customers/{id}", allow(h.HandleDeactivateCustomer, admins)).Methods("DELETE")
+	v1.Handle("/bodegas", allow(h.HandleListBodegas, readers)).Methods("GET")
+	v1.Handle("/bodegas", allow(h.HandleCreateBodega, admins)).Methods("POST")
+	v1.Handle("/bodegas/{code}", allow(h.HandleGetBodega, readers)).Methods("GET")
+	v1.Handle("/bodegas/{code}", allow(h.HandlePatchBodega, admins)).Methods("PATCH")
+	v1.Handle("/bodegas/{code}", allow(h.HandleDeleteBodega, admins)).Methods("DELETE")
+	v1.Handle("/proveedores", allow(h.HandleListProveedores, readers)).Methods("GET")
+	v1.Handle("/proveedores", allow(h.HandleCreateProveedor, admins)).Methods("POST")
+	v1.Handle("/proveedores/{code}", allow(h.HandleGetProveedor, readers)).Methods("GET")
+	v1.Handle("/proveedores/{code}", allow(h.HandlePatchProveedor, admins)).Methods("PATCH")
+	v1.Handle("/proveedores/{code}", allow(h.HandleDeleteProveedor, admins)).Methods("DELETE")
+	v1.Handle("/reports/movements", allow(h.HandleMovementsReport, readers)).Methods("GET")
+	v1.Handle("/inventory", allow(h.HandleInventory, readers)).Methods("GET")
+	v1.Handle("/inventory/snapshots", allow(h.HandleListSnapshots, readers)).Methods("GET")
+	v1.Handle("/inventory/snapshots", allow(h.HandleCloseInventory, managers)).Methods("POST")
+	v1.Handle("/inventory/snapshots/{period}", allow(h.HandleGetSnapshot, readers)).Methods("GET")
+	v1.Handle("/inventory/reconciliation", allow(h.HandleReconciliation, staff)).Methods("POST")
+
+	// Legacy RPC-style routes, kept as aliases for the Retool apps
+	protected.Handle("/entradas", allow(h.HandleEntradasSubmit, staff)).Methods("POST")
+	protected.Handle("/entradas-data", allow(h.HandleProvideEntradasData, readers)).Methods("POST")
+	protected.Handle("/salidas", allow(h.HandleSalidasSubmit, staff)).Methods("POST")
+	protected.Handle("/salidas-data", allow(h.HandleProvideSalidasData, readers)).Methods("POST")
+	protected.Handle("/query-entrada", allow(h.QueryEntrada, readers)).Methods("POST")
+	protected.Handle("/query-salida", allow(h.QuerySalida, readers)).Methods("POST")
+	protected.Handle("/update-asn", allow(h.HandleASNSubmit, managers)).Methods("POST")
+	protected.Handle("/entradas/{id}/asn-history", allow(h.HandleASNHistory, staff)).Methods("GET")
+	protected.Handle("/get-customers", allow(h.HandleProvideCustomers, staff)).Methods("GET")
+	protected.Handle("/create-customer", allow(h.HandleCreateCustomer, admins)).Methods("POST")
+	protected.Handle("/audit", allow(h.HandleAuditQuery, managers)).Methods("GET")
 	return r
 }
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+)
+
+// Reports whether the entrada passes every condition of the filter
+func (f EntradaFilter) matches(e models.EntradasData) bool {
+	if !f.From.IsZero() && e.FechaRecepcion.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.FechaRecepcion.Before(f.To) {
+		return false
+	}
+	if f.BodegaRecepcion != "" && e.BodegaRecepcion != f.BodegaRecepcion {
+		return false
+	}
+	if f.Cliente != "" && e.Cliente != f.Cliente {
+		return false
+	}
+	if f.ProveedorRecepcion != "" && e.ProveedorRecepcion != f.ProveedorRecepcion {
+		return false
+	}
+	if f.TipoDelivery != "" && e.TipoDelivery != f.TipoDelivery {
+		return false
+	}
+	if f.HasASN != nil && (e.ASN != "") != *f.HasASN {
+		return false
+	}
+	return f.IncludeDeleted || !e.Deleted()
+}
+
+// Returns the field the listing is ordered by
+func (f EntradaFilter) sortField() string {
+	if f.SortBy == "" {
+		return "FechaRecepcion"
+	}
+	return f.SortBy
+}
+
+// Reports whether a sorts before b, ties are broken by FechaRecepcion
+func (f EntradaFilter) less(a, b models.EntradasData) bool {
+	var c int
+	switch f.sortField() {
+	case "Cantidad":
+		c = cmp.Compare(a.Cantidad, b.Cantidad)
+	case "BodegaRecepcion":
+		c = strings.Compare(a.BodegaRecepcion, b.BodegaRecepcion)
+	case "Cliente":
+		c = strings.Compare(a.Cliente, b.Cliente)
+	case "ProveedorRecepcion":
+		c = strings.Compare(a.ProveedorRecepcion, b.ProveedorRecepcion)
+	case "TipoDelivery":
+		c = strings.Compare(a.TipoDelivery, b.TipoDelivery)
+	case "CreatedAt":
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = a.FechaRecepcion.Compare(b.FechaRecepcion)
+	}
+	if f.Descending {
+		return c > 0
+	}
+	return c < 0
+}
+
+// Reports whether the salida passes every condition of the filter
+func (f SalidaFilter) matches(s models.SalidasData) bool {
+	if !f.From.IsZero() && s.FechaSalida.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !s.FechaSalida.Before(f.To) {
+		return false
+	}
+	if f.BodegaSalida != "" && s.BodegaSalida != f.BodegaSalida {
+		return false
+	}
+	if f.Cliente != "" && s.Cliente != f.Cliente {
+		return false
+	}
+	if f.ProveedorSalida != "" && s.ProveedorSalida != f.ProveedorSalida {
+		return false
+	}
+	return f.IncludeDeleted || !s.Deleted()
+}
+
+// Returns the field the listing is ordered by
+func (f SalidaFilter) sortField() string {
+	if f.SortBy == "" {
+		return "FechaSalida"
+	}
+	return f.SortBy
+}
+
+// Reports whether a sorts before b, ties are broken by FechaSalida
+func (f SalidaFilter) less(a, b models.SalidasData) bool {
+	var c int
+	switch f.sortField() {
+	case "BodegaSalida":
+		c = strings.Compare(a.BodegaSalida, b.BodegaSalida)
+	case "Cliente":
+		c = strings.Compare(a.Cliente, b.Cliente)
+	case "ProveedorSalida":
+		c = strings.Compare(a.ProveedorSalida, b.ProveedorSalida)
+	case "CreatedAt":
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = a.FechaSalida.Compare(b.FechaSalida)
+	}
+	if f.Descending {
+		return c > 0
+	}
+	return c < 0
+}
+
+// Stops an iteration once a page is full
+var errPageFull = errors.New("page full")
+
+// Page tokens carry the ID of the last document of a page, opaque to clients
+func encodePageToken(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+func decodePageToken(token string) (string, error) {
+	id, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(id) == 0 {
+		return "", ErrInvalidPageToken
+	}
+	return string(id), nil
+}
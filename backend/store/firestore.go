@@ -0,0 +1,1008 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// GCP project and Firestore database holding the app collections
+	ProjectID  = "b-materials"
+	DatabaseID = "app-in-out-good"
+
+	// Collection names, also used as audit log targets
+	EntradasCollection    = "entradas"
+	SalidasCollection     = "salidas"
+	CustomersCollection   = "customers"
+	BodegasCollection     = "bodegas"
+	ProveedoresCollection = "proveedores"
+	InventoryCollection   = "inventory"
+	SnapshotsCollection   = "inventory_snapshots"
+	AuditCollection       = "audit_log"
+
+	// Subcollection of each entrada
+	asnHistoryCollection = "asn_history"
+	// Subcollection of each inventory snapshot
+	snapshotBalancesCollection = "balances"
+)
+
+// NewFirestore returns a Store backed by the given Firestore client.
+// The client is closed when the Store is closed.
+func NewFirestore(client *firestore.Client) *Store {
+	return &Store{
+		Entradas:    &firestoreEntradas{client: client},
+		Salidas:     &firestoreSalidas{client: client},
+		Customers:   &firestoreCustomers{client: client},
+		Bodegas:     &firestoreBodegas{client: client},
+		Proveedores: &firestoreProveedores{client: client},
+		Inventory:   &firestoreInventory{client: client},
+		Snapshots:   &firestoreSnapshots{client: client},
+		Audit:       &firestoreAudit{client: client},
+		close:       client.Close,
+	}
+}
+
+// Orders a listing by field, then by its date field so ties keep a stable order.
+// Sorting by a field other than the date needs a composite index in Firestore.
+func orderBy(query firestore.Query, field, dateField string, descending bool) firestore.Query {
+	dir := firestore.Asc
+	if descending {
+		dir = firestore.Desc
+	}
+	query = query.OrderBy(field, dir)
+	if field != dateField {
+		query = query.OrderBy(dateField, dir)
+	}
+	return query
+}
+
+// Translates Firestore status codes into store errors
+func mapError(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.AlreadyExists:
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// Converts patch fields into Firestore updates
+func toUpdates(fields map[string]interface{}) []firestore.Update {
+	updates := make([]firestore.Update, 0, len(fields))
+	for path, value := range fields {
+		updates = append(updates, firestore.Update{Path: path, Value: value})
+	}
+	return updates
+}
+
+// Fields written when voiding a movement
+func deletionUpdates(d models.Deletion) []firestore.Update {
+	return []firestore.Update{
+		{Path: "DeletedAt", Value: d.DeletedAt},
+		{Path: "DeletedBy", Value: d.DeletedBy},
+		{Path: "DeleteReason", Value: d.DeleteReason},
+	}
+}
+
+// Removes the deletion fields so restored movements look like never voided ones
+func restoreUpdates(restoredBy string, at time.Time) []firestore.Update {
+	return []firestore.Update{
+		{Path: "DeletedAt", Value: firestore.Delete},
+		{Path: "DeletedBy", Value: firestore.Delete},
+		{Path: "DeleteReason", Value: firestore.Delete},
+		{Path: "UpdatedBy", Value: restoredBy},
+		{Path: "UpdatedAt", Value: at},
+	}
+}
+
+type firestoreEntradas struct {
+	client *firestore.Client
+}
+
+func (s *firestoreEntradas) Create(ctx context.Context, entrada models.Entradas) (string, error) {
+	ref := s.client.Collection(EntradasCollection).NewDoc()
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := applyInventory(tx, s.client, entrada.Data().InventoryDeltas(), entrada.CreatedAt, false); err != nil {
+			return err
+		}
+		return tx.Create(ref, entrada)
+	})
+	if err != nil {
+		return "", mapError(err)
+	}
+	return ref.ID, nil
+}
+
+func (s *firestoreEntradas) Get(ctx context.Context, id string) (models.EntradasData, error) {
+	var entrada models.EntradasData
+	docSnap, err := s.client.Collection(EntradasCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return entrada, mapError(err)
+	}
+	err = docSnap.DataTo(&entrada)
+	return entrada, err
+}
+
+// Builds the Firestore query of a listing, resumed after the page token if any
+func (s *firestoreEntradas) query(ctx context.Context, filter EntradaFilter) (firestore.Query, error) {
+	query := s.client.Collection(EntradasCollection).Query
+	if !filter.From.IsZero() {
+		query = query.Where("FechaRecepcion", ">=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("FechaRecepcion", "<", filter.To)
+	}
+	if filter.BodegaRecepcion != "" {
+		query = query.Where("BodegaRecepcion", "==", filter.BodegaRecepcion)
+	}
+	if filter.Cliente != "" {
+		query = query.Where("Cliente", "==", filter.Cliente)
+	}
+	if filter.ProveedorRecepcion != "" {
+		query = query.Where("ProveedorRecepcion", "==", filter.ProveedorRecepcion)
+	}
+	if filter.TipoDelivery != "" {
+		query = query.Where("TipoDelivery", "==", filter.TipoDelivery)
+	}
+	query = orderBy(query, filter.sortField(), "FechaRecepcion", filter.Descending)
+	if filter.PageToken != "" {
+		cursor, err := s.pageCursor(ctx, filter.PageToken)
+		if err != nil {
+			return query, err
+		}
+		query = query.StartAfter(cursor)
+	}
+	return query, nil
+}
+
+func (s *firestoreEntradas) List(ctx context.Context, filter EntradaFilter) ([]models.EntradasDataWithID, string, error) {
+	var results []models.EntradasDataWithID
+	var next string
+	err := s.Each(ctx, filter, func(entrada models.EntradasDataWithID) error {
+		// Another match exists past a full page, the next page starts after the last entrada
+		if filter.PageSize > 0 && len(results) == filter.PageSize {
+			next = encodePageToken(results[len(results)-1].ID)
+			return errPageFull
+		}
+		results = append(results, entrada)
+		return nil
+	})
+	if err != nil && err != errPageFull {
+		return nil, "", err
+	}
+	return results, next, nil
+}
+
+func (s *firestoreEntradas) Each(ctx context.Context, filter EntradaFilter, fn func(models.EntradasDataWithID) error) error {
+	query, err := s.query(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var entrada models.EntradasData
+		if err := doc.DataTo(&entrada); err != nil {
+			return err
+		}
+		// Missing ASN and DeletedAt fields cannot be matched by a query, they are checked here
+		if !filter.matches(entrada) {
+			continue
+		}
+		if err := fn(models.EntradasDataWithID{ID: doc.Ref.ID, EntradasData: entrada}); err != nil {
+			return err
+		}
+	}
+}
+
+// Returns the entrada a page token points to
+func (s *firestoreEntradas) pageCursor(ctx context.Context, token string) (*firestore.DocumentSnapshot, error) {
+	id, err := decodePageToken(token)
+	if err != nil {
+		return nil, err
+	}
+	docSnap, err := s.client.Collection(EntradasCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrInvalidPageToken
+	}
+	return docSnap, err
+}
+
+func (s *firestoreEntradas) FindByASN(ctx context.Context, asn string) (models.EntradasData, error) {
+	iter := s.client.Collection(EntradasCollection).
+		Where("ASN", "==", asn).
+		Documents(ctx)
+	defer iter.Stop()
+
+	// Return the first entrada holding the ASN that was not voided
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return models.EntradasData{}, ErrNotFound
+		}
+		if err != nil {
+			return models.EntradasData{}, err
+		}
+		var entrada models.EntradasData
+		if err := doc.DataTo(&entrada); err != nil {
+			return entrada, err
+		}
+		if !entrada.Deleted() {
+			return entrada, nil
+		}
+	}
+}
+
+func (s *firestoreEntradas) UpdateASN(ctx context.Context, asn models.ASN, reason string) (models.EntradasData, error) {
+	var before models.EntradasData
+	ref := s.client.Collection(EntradasCollection).Doc(asn.ID)
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docSnap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		before = models.EntradasData{}
+		if err := docSnap.DataTo(&before); err != nil {
+			return err
+		}
+		if before.Deleted() {
+			return ErrDeleted
+		}
+
+		if err := tx.Update(ref, []firestore.Update{
+			{Path: "ASN", Value: asn.ASN},
+			{Path: "FechaAjusteASN", Value: asn.FechaAjusteASN},
+			{Path: "UpdatedBy", Value: asn.UpdatedBy},
+			{Path: "UpdatedAt", Value: asn.UpdatedAt},
+		}); err != nil {
+			return err
+		}
+
+		return tx.Create(ref.Collection(asnHistoryCollection).NewDoc(), models.ASNChange{
+			OldASN:         before.ASN,
+			NewASN:         asn.ASN,
+			FechaAjusteASN: asn.FechaAjusteASN,
+			ChangedBy:      asn.UpdatedBy,
+			ChangedAt:      asn.UpdatedAt,
+			Reason:         reason,
+		})
+	})
+	return before, mapError(err)
+}
+
+func (s *firestoreEntradas) ASNHistory(ctx context.Context, id string) ([]models.ASNChange, error) {
+	ref := s.client.Collection(EntradasCollection).Doc(id)
+	if _, err := ref.Get(ctx); err != nil {
+		return nil, mapError(err)
+	}
+
+	docs, err := ref.Collection(asnHistoryCollection).OrderBy("changed_at", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []models.ASNChange
+	for _, doc := range docs {
+		var change models.ASNChange
+		if err := doc.DataTo(&change); err != nil {
+			return nil, err
+		}
+		change.ID = doc.Ref.ID
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Reads the entrada inside a transaction and applies the updates returned by fn,
+// which also returns the entrada as it will be. The inventory balances move by the
+// difference between both versions in the same transaction, and none may go
+// negative unless ctx carries a stock override.
+func (s *firestoreEntradas) mutate(ctx context.Context, id string, fn func(models.EntradasData) ([]firestore.Update, models.EntradasData, error)) (models.EntradasData, models.EntradasData, error) {
+	var before, after models.EntradasData
+	ref := s.client.Collection(EntradasCollection).Doc(id)
+
+	strict := strictStock(ctx)
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		before = models.EntradasData{}
+		docSnap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := docSnap.DataTo(&before); err != nil {
+			return err
+		}
+		var updates []firestore.Update
+		updates, after, err = fn(before)
+		if err != nil {
+			return err
+		}
+		deltas := inventoryChange(before.InventoryDeltas(), after.InventoryDeltas())
+		if err := applyInventory(tx, s.client, deltas, time.Now().UTC(), strict); err != nil {
+			return err
+		}
+		return tx.Update(ref, updates)
+	})
+	if err != nil {
+		return before, before, mapError(err)
+	}
+	return before, after, nil
+}
+
+func (s *firestoreEntradas) Update(ctx context.Context, id string, patch models.EntradasPatch) (models.EntradasData, models.EntradasData, error) {
+	return s.mutate(ctx, id, func(current models.EntradasData) ([]firestore.Update, models.EntradasData, error) {
+		if current.Deleted() {
+			return nil, current, ErrDeleted
+		}
+		return toUpdates(patch.Fields()), patch.Apply(current), nil
+	})
+}
+
+func (s *firestoreEntradas) SoftDelete(ctx context.Context, id string, d models.Deletion) (models.EntradasData, models.EntradasData, error) {
+	return s.mutate(ctx, id, func(current models.EntradasData) ([]firestore.Update, models.EntradasData, error) {
+		if current.Deleted() {
+			return nil, current, ErrDeleted
+		}
+		return deletionUpdates(d), current.WithDeletion(d), nil
+	})
+}
+
+func (s *firestoreEntradas) Restore(ctx context.Context, id, restoredBy string, at time.Time) (models.EntradasData, models.EntradasData, error) {
+	return s.mutate(ctx, id, func(current models.EntradasData) ([]firestore.Update, models.EntradasData, error) {
+		if !current.Deleted() {
+			return nil, current, ErrNotDeleted
+		}
+		after := current.WithDeletion(models.Deletion{})
+		after.UpdatedBy = restoredBy
+		after.UpdatedAt = at
+		return restoreUpdates(restoredBy, at), after, nil
+	})
+}
+
+type firestoreSalidas struct {
+	client *firestore.Client
+}
+
+func (s *firestoreSalidas) Create(ctx context.Context, salida models.Salidas) (string, error) {
+	ref := s.client.Collection(SalidasCollection).NewDoc()
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// Stock may only go negative when a supervisor overrode the check
+		strict := salida.StockOverrideBy == ""
+		if err := applyInventory(tx, s.client, salida.Data().InventoryDeltas(), salida.CreatedAt, strict); err != nil {
+			return err
+		}
+		return tx.Create(ref, salida)
+	})
+	if err != nil {
+		return "", mapError(err)
+	}
+	return ref.ID, nil
+}
+
+func (s *firestoreSalidas) Get(ctx context.Context, id string) (models.SalidasData, error) {
+	var salida models.SalidasData
+	docSnap, err := s.client.Collection(SalidasCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return salida, mapError(err)
+	}
+	err = docSnap.DataTo(&salida)
+	return salida, err
+}
+
+// Builds the Firestore query of a listing, resumed after the page token if any
+func (s *firestoreSalidas) query(ctx context.Context, filter SalidaFilter) (firestore.Query, error) {
+	query := s.client.Collection(SalidasCollection).Query
+	if !filter.From.IsZero() {
+		query = query.Where("FechaSalida", ">=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("FechaSalida", "<", filter.To)
+	}
+	if filter.BodegaSalida != "" {
+		query = query.Where("BodegaSalida", "==", filter.BodegaSalida)
+	}
+	if filter.Cliente != "" {
+		query = query.Where("Cliente", "==", filter.Cliente)
+	}
+	if filter.ProveedorSalida != "" {
+		query = query.Where("ProveedorSalida", "==", filter.ProveedorSalida)
+	}
+	query = orderBy(query, filter.sortField(), "FechaSalida", filter.Descending)
+	if filter.PageToken != "" {
+		cursor, err := s.pageCursor(ctx, filter.PageToken)
+		if err != nil {
+			return query, err
+		}
+		query = query.StartAfter(cursor)
+	}
+	return query, nil
+}
+
+func (s *firestoreSalidas) List(ctx context.Context, filter SalidaFilter) ([]models.SalidasDataWithID, string, error) {
+	var results []models.SalidasDataWithID
+	var next string
+	err := s.Each(ctx, filter, func(salida models.SalidasDataWithID) error {
+		// Another match exists past a full page, the next page starts after the last salida
+		if filter.PageSize > 0 && len(results) == filter.PageSize {
+			next = encodePageToken(results[len(results)-1].ID)
+			return errPageFull
+		}
+		results = append(results, salida)
+		return nil
+	})
+	if err != nil && err != errPageFull {
+		return nil, "", err
+	}
+	return results, next, nil
+}
+
+func (s *firestoreSalidas) Each(ctx context.Context, filter SalidaFilter, fn func(models.SalidasDataWithID) error) error {
+	query, err := s.query(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var salida models.SalidasData
+		if err := doc.DataTo(&salida); err != nil {
+			return err
+		}
+		// Documents without DeletedAt cannot be matched by a query, voided ones are skipped here
+		if !filter.matches(salida) {
+			continue
+		}
+		if err := fn(models.SalidasDataWithID{ID: doc.Ref.ID, SalidasData: salida}); err != nil {
+			return err
+		}
+	}
+}
+
+// Returns the salida a page token points to
+func (s *firestoreSalidas) pageCursor(ctx context.Context, token string) (*firestore.DocumentSnapshot, error) {
+	id, err := decodePageToken(token)
+	if err != nil {
+		return nil, err
+	}
+	docSnap, err := s.client.Collection(SalidasCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrInvalidPageToken
+	}
+	return docSnap, err
+}
+
+// Reads the salida inside a transaction and applies the updates returned by fn,
+// which also returns the salida as it will be. The inventory balances move by the
+// difference between both versions in the same transaction, and none may go
+// negative unless ctx carries a stock override.
+func (s *firestoreSalidas) mutate(ctx context.Context, id string, fn func(models.SalidasData) ([]firestore.Update, models.SalidasData, error)) (models.SalidasData, models.SalidasData, error) {
+	var before, after models.SalidasData
+	ref := s.client.Collection(SalidasCollection).Doc(id)
+
+	strict := strictStock(ctx)
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		before = models.SalidasData{}
+		docSnap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := docSnap.DataTo(&before); err != nil {
+			return err
+		}
+		var updates []firestore.Update
+		updates, after, err = fn(before)
+		if err != nil {
+			return err
+		}
+		deltas := inventoryChange(before.InventoryDeltas(), after.InventoryDeltas())
+		if err := applyInventory(tx, s.client, deltas, time.Now().UTC(), strict); err != nil {
+			return err
+		}
+		return tx.Update(ref, updates)
+	})
+	if err != nil {
+		return before, before, mapError(err)
+	}
+	return before, after, nil
+}
+
+func (s *firestoreSalidas) Update(ctx context.Context, id string, patch models.SalidasPatch) (models.SalidasData, models.SalidasData, error) {
+	return s.mutate(ctx, id, func(current models.SalidasData) ([]firestore.Update, models.SalidasData, error) {
+		if current.Deleted() {
+			return nil, current, ErrDeleted
+		}
+		return toUpdates(patch.Fields()), patch.Apply(current), nil
+	})
+}
+
+func (s *firestoreSalidas) SoftDelete(ctx context.Context, id string, d models.Deletion) (models.SalidasData, models.SalidasData, error) {
+	return s.mutate(ctx, id, func(current models.SalidasData) ([]firestore.Update, models.SalidasData, error) {
+		if current.Deleted() {
+			return nil, current, ErrDeleted
+		}
+		return deletionUpdates(d), current.WithDeletion(d), nil
+	})
+}
+
+func (s *firestoreSalidas) Restore(ctx context.Context, id, restoredBy string, at time.Time) (models.SalidasData, models.SalidasData, error) {
+	return s.mutate(ctx, id, func(current models.SalidasData) ([]firestore.Update, models.SalidasData, error) {
+		if !current.Deleted() {
+			return nil, current, ErrNotDeleted
+		}
+		after := current.WithDeletion(models.Deletion{})
+		after.UpdatedBy = restoredBy
+		after.UpdatedAt = at
+		return restoreUpdates(restoredBy, at), after, nil
+	})
+}
+
+type firestoreCustomers struct {
+	client *firestore.Client
+}
+
+func (s *firestoreCustomers) Create(ctx context.Context, id string, customer models.Customer) error {
+	_, err := s.client.Collection(CustomersCollection).Doc(id).Create(ctx, customer)
+	return mapError(err)
+}
+
+func (s *firestoreCustomers) Get(ctx context.Context, id string) (models.Customer, error) {
+	var customer models.Customer
+	docSnap, err := s.client.Collection(CustomersCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return customer, mapError(err)
+	}
+	err = docSnap.DataTo(&customer)
+	customer.ID = id
+	if customer.Status == "" {
+		customer.Status = models.CustomerActive
+	}
+	return customer, err
+}
+
+func (s *firestoreCustomers) List(ctx context.Context) ([]models.Customer, error) {
+	docs, err := s.client.Collection(CustomersCollection).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var customers []models.Customer
+	for _, doc := range docs {
+		var customer models.Customer
+		if err := doc.DataTo(&customer); err != nil {
+			return nil, err
+		}
+		customer.ID = doc.Ref.ID
+		// Customers created before statuses existed are active
+		if customer.Status == "" {
+			customer.Status = models.CustomerActive
+		}
+		customers = append(customers, customer)
+	}
+	return customers, nil
+}
+
+// Reads the customer inside a transaction and writes what write returns
+func (s *firestoreCustomers) mutate(ctx context.Context, id string, write func(tx *firestore.Transaction, ref *firestore.DocumentRef) error) (models.Customer, error) {
+	var before models.Customer
+	ref := s.client.Collection(CustomersCollection).Doc(id)
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		before = models.Customer{}
+		docSnap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := docSnap.DataTo(&before); err != nil {
+			return err
+		}
+		return write(tx, ref)
+	})
+	before.ID = id
+	return before, mapError(err)
+}
+
+func (s *firestoreCustomers) Replace(ctx context.Context, id string, customer models.Customer) (models.Customer, error) {
+	return s.mutate(ctx, id, func(tx *firestore.Transaction, ref *firestore.DocumentRef) error {
+		return tx.Set(ref, customer)
+	})
+}
+
+func (s *firestoreCustomers) Update(ctx context.Context, id string, patch models.CustomerPatch) (models.Customer, models.Customer, error) {
+	before, err := s.mutate(ctx, id, func(tx *firestore.Transaction, ref *firestore.DocumentRef) error {
+		updates := toUpdates(patch.Fields())
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Update(ref, updates)
+	})
+	if err != nil {
+		return before, before, err
+	}
+	return before, patch.Apply(before), nil
+}
+
+type firestoreBodegas struct {
+	client *firestore.Client
+}
+
+func (s *firestoreBodegas) Create(ctx context.Context, bodega models.Bodega) error {
+	_, err := s.client.Collection(BodegasCollection).Doc(bodega.Code).Create(ctx, bodega)
+	return mapError(err)
+}
+
+func (s *firestoreBodegas) Get(ctx context.Context, code string) (models.Bodega, error) {
+	var bodega models.Bodega
+	docSnap, err := s.client.Collection(BodegasCollection).Doc(code).Get(ctx)
+	if err != nil {
+		return bodega, mapError(err)
+	}
+	err = docSnap.DataTo(&bodega)
+	return bodega, err
+}
+
+func (s *firestoreBodegas) List(ctx context.Context, includeInactive bool) ([]models.Bodega, error) {
+	query := s.client.Collection(BodegasCollection).Query
+	if !includeInactive {
+		query = query.Where("active", "==", true)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Documents come ordered by ID, which is the code
+	var bodegas []models.Bodega
+	for _, doc := range docs {
+		var bodega models.Bodega
+		if err := doc.DataTo(&bodega); err != nil {
+			return nil, err
+		}
+		bodegas = append(bodegas, bodega)
+	}
+	return bodegas, nil
+}
+
+func (s *firestoreBodegas) Update(ctx context.Context, code string, patch models.BodegaPatch) (models.Bodega, models.Bodega, error) {
+	var before models.Bodega
+	ref := s.client.Collection(BodegasCollection).Doc(code)
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		before = models.Bodega{}
+		docSnap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := docSnap.DataTo(&before); err != nil {
+			return err
+		}
+		return tx.Update(ref, toUpdates(patch.Fields()))
+	})
+	if err != nil {
+		return before, before, mapError(err)
+	}
+	return before, patch.Apply(before), nil
+}
+
+type firestoreProveedores struct {
+	client *firestore.Client
+}
+
+func (s *firestoreProveedores) Create(ctx context.Context, proveedor models.Proveedor) error {
+	_, err := s.client.Collection(ProveedoresCollection).Doc(proveedor.Code).Create(ctx, proveedor)
+	return mapError(err)
+}
+
+func (s *firestoreProveedores) Get(ctx context.Context, code string) (models.Proveedor, error) {
+	var proveedor models.Proveedor
+	docSnap, err := s.client.Collection(ProveedoresCollection).Doc(code).Get(ctx)
+	if err != nil {
+		return proveedor, mapError(err)
+	}
+	err = docSnap.DataTo(&proveedor)
+	return proveedor, err
+}
+
+func (s *firestoreProveedores) List(ctx context.Context, includeInactive bool) ([]models.Proveedor, error) {
+	query := s.client.Collection(ProveedoresCollection).Query
+	if !includeInactive {
+		query = query.Where("active", "==", true)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Documents come ordered by ID, which is the code
+	var proveedores []models.Proveedor
+	for _, doc := range docs {
+		var proveedor models.Proveedor
+		if err := doc.DataTo(&proveedor); err != nil {
+			return nil, err
+		}
+		proveedores = append(proveedores, proveedor)
+	}
+	return proveedores, nil
+}
+
+func (s *firestoreProveedores) Update(ctx context.Context, code string, patch models.ProveedorPatch) (models.Proveedor, models.Proveedor, error) {
+	var before models.Proveedor
+	ref := s.client.Collection(ProveedoresCollection).Doc(code)
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		before = models.Proveedor{}
+		docSnap, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := docSnap.DataTo(&before); err != nil {
+			return err
+		}
+		return tx.Update(ref, toUpdates(patch.Fields()))
+	})
+	if err != nil {
+		return before, before, mapError(err)
+	}
+	return before, patch.Apply(before), nil
+}
+
+// Adds the deltas to the inventory balances inside a transaction. The balances
+// are read before anything is written, as transactions require. When strict
+// nothing is written if a balance would go negative.
+func applyInventory(tx *firestore.Transaction, client *firestore.Client, deltas map[models.InventoryKey]int64, at time.Time, strict bool) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	keys := sortedKeys(deltas)
+	refs := make([]*firestore.DocumentRef, len(keys))
+	for i, key := range keys {
+		refs[i] = client.Collection(InventoryCollection).Doc(key.ID())
+	}
+	docs, err := tx.GetAll(refs)
+	if err != nil {
+		return err
+	}
+
+	current := map[models.InventoryKey]int64{}
+	for i, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var balance models.InventoryBalance
+		if err := doc.DataTo(&balance); err != nil {
+			return err
+		}
+		current[keys[i]] = balance.Cantidad
+	}
+	if strict {
+		if err := checkStock(current, deltas); err != nil {
+			return err
+		}
+	}
+
+	for i, key := range keys {
+		balance := models.InventoryBalance{
+			Cliente:   key.Cliente,
+			Bodega:    key.Bodega,
+			SKU:       key.SKU,
+			Cantidad:  current[key] + deltas[key],
+			UpdatedAt: at,
+		}
+		if err := tx.Set(refs[i], balance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type firestoreInventory struct {
+	client *firestore.Client
+}
+
+func (s *firestoreInventory) List(ctx context.Context, filter InventoryFilter) ([]models.InventoryBalance, error) {
+	query := s.client.Collection(InventoryCollection).Query
+	if filter.Cliente != "" {
+		query = query.Where("cliente", "==", filter.Cliente)
+	}
+	if filter.Bodega != "" {
+		query = query.Where("bodega", "==", filter.Bodega)
+	}
+	if filter.SKU != "" {
+		query = query.Where("sku", "==", filter.SKU)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var balances []models.InventoryBalance
+	for _, doc := range docs {
+		var balance models.InventoryBalance
+		if err := doc.DataTo(&balance); err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+	// Sorted here so that no composite index is needed
+	models.SortBalances(balances)
+	return balances, nil
+}
+
+type firestoreSnapshots struct {
+	client *firestore.Client
+}
+
+// Writes the balances before the snapshot document, so that a failed close
+// never shows up as complete. Balances left over from a previous close of the
+// period are deleted.
+func (s *firestoreSnapshots) Save(ctx context.Context, snapshot models.InventorySnapshot) error {
+	ref := s.client.Collection(SnapshotsCollection).Doc(snapshot.Period)
+	balancesRef := ref.Collection(snapshotBalancesCollection)
+
+	keep := map[string]bool{}
+	for _, balance := range snapshot.Balances {
+		keep[models.InventoryKey{Cliente: balance.Cliente, Bodega: balance.Bodega, SKU: balance.SKU}.ID()] = true
+	}
+	previous, err := balancesRef.DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	writer := s.client.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, doc := range previous {
+		if keep[doc.ID] {
+			continue
+		}
+		job, err := writer.Delete(doc)
+		if err != nil {
+			writer.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	for _, balance := range snapshot.Balances {
+		key := models.InventoryKey{Cliente: balance.Cliente, Bodega: balance.Bodega, SKU: balance.SKU}
+		job, err := writer.Set(balancesRef.Doc(key.ID()), balance)
+		if err != nil {
+			writer.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	writer.End()
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+
+	_, err = ref.Set(ctx, snapshot)
+	return err
+}
+
+func (s *firestoreSnapshots) Get(ctx context.Context, period string, filter InventoryFilter) (models.InventorySnapshot, error) {
+	var snapshot models.InventorySnapshot
+	ref := s.client.Collection(SnapshotsCollection).Doc(period)
+	docSnap, err := ref.Get(ctx)
+	if err != nil {
+		return snapshot, mapError(err)
+	}
+	if err := docSnap.DataTo(&snapshot); err != nil {
+		return snapshot, err
+	}
+
+	query := ref.Collection(snapshotBalancesCollection).Query
+	if filter.Cliente != "" {
+		query = query.Where("cliente", "==", filter.Cliente)
+	}
+	if filter.Bodega != "" {
+		query = query.Where("bodega", "==", filter.Bodega)
+	}
+	if filter.SKU != "" {
+		query = query.Where("sku", "==", filter.SKU)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return snapshot, err
+	}
+	for _, doc := range docs {
+		var balance models.InventoryBalance
+		if err := doc.DataTo(&balance); err != nil {
+			return snapshot, err
+		}
+		snapshot.Balances = append(snapshot.Balances, balance)
+	}
+	models.SortBalances(snapshot.Balances)
+	return snapshot, nil
+}
+
+func (s *firestoreSnapshots) List(ctx context.Context) ([]models.InventorySnapshot, error) {
+	docs, err := s.client.Collection(SnapshotsCollection).OrderBy("period", firestore.Desc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []models.InventorySnapshot
+	for _, doc := range docs {
+		var snapshot models.InventorySnapshot
+		if err := doc.DataTo(&snapshot); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+type firestoreAudit struct {
+	client *firestore.Client
+}
+
+func (s *firestoreAudit) Append(ctx context.Context, entry models.AuditEntry) error {
+	_, _, err := s.client.Collection(AuditCollection).Add(ctx, entry)
+	return err
+}
+
+func (s *firestoreAudit) Query(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	query := s.client.Collection(AuditCollection).Query
+	if filter.Collection != "" {
+		query = query.Where("collection", "==", filter.Collection)
+	}
+	if filter.DocumentID != "" {
+		query = query.Where("document_id", "==", filter.DocumentID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor", "==", filter.Actor)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp", ">=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp", "<", filter.To)
+	}
+	query = query.OrderBy("timestamp", firestore.Desc)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.AuditEntry
+	for _, doc := range docs {
+		var entry models.AuditEntry
+		if err := doc.DataTo(&entry); err != nil {
+			return nil, err
+		}
+		entry.ID = doc.Ref.ID
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+)
+
+type stockOverrideKey struct{}
+
+// WithStockOverride lets the writes made with the returned context drive
+// inventory balances below zero, on behalf of the supervisor uid. An empty uid
+// returns ctx unchanged.
+func WithStockOverride(ctx context.Context, uid string) context.Context {
+	if uid == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, stockOverrideKey{}, uid)
+}
+
+// Reports whether balances must stay non-negative for the writes made with ctx
+func strictStock(ctx context.Context) bool {
+	uid, _ := ctx.Value(stockOverrideKey{}).(string)
+	return uid == ""
+}
+
+// InsufficientStockError tells which balance a write would drive negative.
+// It matches ErrInsufficientStock with errors.Is.
+type InsufficientStockError struct {
+	Key       models.InventoryKey
+	Available int64
+	Requested int64
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock of %s for %s in %s: %d available, %d requested",
+		e.Key.SKU, e.Key.Cliente, e.Key.Bodega, e.Available, e.Requested)
+}
+
+func (e *InsufficientStockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+func (f InventoryFilter) matches(b models.InventoryBalance) bool {
+	return (f.Cliente == "" || b.Cliente == f.Cliente) &&
+		(f.Bodega == "" || b.Bodega == f.Bodega) &&
+		(f.SKU == "" || b.SKU == f.SKU)
+}
+
+// Returns what a movement changing from before to after adds to each balance,
+// balances it leaves as they were are dropped
+func inventoryChange(before, after map[models.InventoryKey]int64) map[models.InventoryKey]int64 {
+	change := map[models.InventoryKey]int64{}
+	for key, qty := range after {
+		change[key] += qty
+	}
+	for key, qty := range before {
+		change[key] -= qty
+	}
+	for key, qty := range change {
+		if qty == 0 {
+			delete(change, key)
+		}
+	}
+	return change
+}
+
+// Returns the keys of the deltas in a stable order, so that stock errors
+// always name the same balance
+func sortedKeys(deltas map[models.InventoryKey]int64) []models.InventoryKey {
+	keys := make([]models.InventoryKey, 0, len(deltas))
+	for key := range deltas {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.Compare(keys[i].ID(), keys[j].ID()) < 0
+	})
+	return keys
+}
+
+// Checks that no balance goes negative once the deltas are applied
+func checkStock(balances map[models.InventoryKey]int64, deltas map[models.InventoryKey]int64) error {
+	for _, key := range sortedKeys(deltas) {
+		delta := deltas[key]
+		if delta < 0 && balances[key]+delta < 0 {
+			return &InsufficientStockError{Key: key, Available: balances[key], Requested: -delta}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,693 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/google/uuid"
+)
+
+// NewMemory returns a Store that keeps every collection in process memory.
+// Intended for local development and tests, data is lost on restart.
+func NewMemory() *Store {
+	inventory := &memoryInventory{balances: map[models.InventoryKey]models.InventoryBalance{}}
+	return &Store{
+		Entradas:    &memoryEntradas{docs: map[string]models.EntradasData{}, asnHistory: map[string][]models.ASNChange{}, inventory: inventory},
+		Salidas:     &memorySalidas{docs: map[string]models.SalidasData{}, inventory: inventory},
+		Customers:   &memoryCustomers{docs: map[string]models.Customer{}},
+		Bodegas:     &memoryBodegas{docs: map[string]models.Bodega{}},
+		Proveedores: &memoryProveedores{docs: map[string]models.Proveedor{}},
+		Inventory:   inventory,
+		Snapshots:   &memorySnapshots{docs: map[string]models.InventorySnapshot{}},
+		Audit:       &memoryAudit{},
+	}
+}
+
+// Returns the bounds of the page of sorted IDs selected by pageSize and token,
+// and the token of the following page
+func pageBounds(ids []string, pageSize int, token string) (int, int, string, error) {
+	start := 0
+	if token != "" {
+		last, err := decodePageToken(token)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		start = -1
+		for i, id := range ids {
+			if id == last {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return 0, 0, "", ErrInvalidPageToken
+		}
+	}
+	if pageSize <= 0 || start+pageSize >= len(ids) {
+		return start, len(ids), "", nil
+	}
+	end := start + pageSize
+	return start, end, encodePageToken(ids[end-1]), nil
+}
+
+type memoryEntradas struct {
+	mu         sync.RWMutex
+	docs       map[string]models.EntradasData
+	asnHistory map[string][]models.ASNChange
+	inventory  *memoryInventory
+}
+
+func (s *memoryEntradas) Create(ctx context.Context, entrada models.Entradas) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := entrada.Data()
+	if err := s.inventory.apply(data.InventoryDeltas(), entrada.CreatedAt, false); err != nil {
+		return "", err
+	}
+	id := uuid.New().String()
+	s.docs[id] = data
+	return id, nil
+}
+
+func (s *memoryEntradas) Get(ctx context.Context, id string) (models.EntradasData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entrada, ok := s.docs[id]
+	if !ok {
+		return entrada, ErrNotFound
+	}
+	return entrada, nil
+}
+
+// Returns the IDs of the entradas matching the filter in listing order, ties
+// are broken by ID so that pages are stable. The caller holds the lock.
+func (s *memoryEntradas) sortedIDs(filter EntradaFilter) []string {
+	var ids []string
+	for id, entrada := range s.docs {
+		if filter.matches(entrada) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := s.docs[ids[i]], s.docs[ids[j]]
+		if filter.less(a, b) || filter.less(b, a) {
+			return filter.less(a, b)
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func (s *memoryEntradas) List(ctx context.Context, filter EntradaFilter) ([]models.EntradasDataWithID, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.sortedIDs(filter)
+	start, end, next, err := pageBounds(ids, filter.PageSize, filter.PageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	var results []models.EntradasDataWithID
+	for _, id := range ids[start:end] {
+		results = append(results, models.EntradasDataWithID{ID: id, EntradasData: s.docs[id]})
+	}
+	return results, next, nil
+}
+
+func (s *memoryEntradas) Each(ctx context.Context, filter EntradaFilter, fn func(models.EntradasDataWithID) error) error {
+	filter.PageSize = 0
+	results, _, err := s.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, entrada := range results {
+		if err := fn(entrada); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryEntradas) FindByASN(ctx context.Context, asn string) (models.EntradasData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, entrada := range s.docs {
+		if entrada.ASN == asn && !entrada.Deleted() {
+			return entrada, nil
+		}
+	}
+	return models.EntradasData{}, ErrNotFound
+}
+
+func (s *memoryEntradas) UpdateASN(ctx context.Context, asn models.ASN, reason string) (models.EntradasData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[asn.ID]
+	if !ok {
+		return before, ErrNotFound
+	}
+	if before.Deleted() {
+		return before, ErrDeleted
+	}
+	entrada := before
+	entrada.ASN = asn.ASN
+	entrada.FechaAjusteASN = asn.FechaAjusteASN
+	entrada.UpdatedBy = asn.UpdatedBy
+	entrada.UpdatedAt = asn.UpdatedAt
+	s.docs[asn.ID] = entrada
+
+	s.asnHistory[asn.ID] = append(s.asnHistory[asn.ID], models.ASNChange{
+		ID:             uuid.New().String(),
+		OldASN:         before.ASN,
+		NewASN:         asn.ASN,
+		FechaAjusteASN: asn.FechaAjusteASN,
+		ChangedBy:      asn.UpdatedBy,
+		ChangedAt:      asn.UpdatedAt,
+		Reason:         reason,
+	})
+	return before, nil
+}
+
+func (s *memoryEntradas) ASNHistory(ctx context.Context, id string) ([]models.ASNChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.docs[id]; !ok {
+		return nil, ErrNotFound
+	}
+	return append([]models.ASNChange(nil), s.asnHistory[id]...), nil
+}
+
+func (s *memoryEntradas) Update(ctx context.Context, id string, patch models.EntradasPatch) (models.EntradasData, models.EntradasData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[id]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	if before.Deleted() {
+		return before, before, ErrDeleted
+	}
+	after := patch.Apply(before)
+	if err := s.inventory.apply(inventoryChange(before.InventoryDeltas(), after.InventoryDeltas()), time.Now().UTC(), strictStock(ctx)); err != nil {
+		return before, before, err
+	}
+	s.docs[id] = after
+	return before, after, nil
+}
+
+func (s *memoryEntradas) SoftDelete(ctx context.Context, id string, d models.Deletion) (models.EntradasData, models.EntradasData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[id]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	if before.Deleted() {
+		return before, before, ErrDeleted
+	}
+	after := before.WithDeletion(d)
+	if err := s.inventory.apply(inventoryChange(before.InventoryDeltas(), after.InventoryDeltas()), time.Now().UTC(), strictStock(ctx)); err != nil {
+		return before, before, err
+	}
+	s.docs[id] = after
+	return before, after, nil
+}
+
+func (s *memoryEntradas) Restore(ctx context.Context, id, restoredBy string, at time.Time) (models.EntradasData, models.EntradasData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[id]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	if !before.Deleted() {
+		return before, before, ErrNotDeleted
+	}
+	after := before.WithDeletion(models.Deletion{})
+	after.UpdatedBy = restoredBy
+	after.UpdatedAt = at
+	if err := s.inventory.apply(inventoryChange(before.InventoryDeltas(), after.InventoryDeltas()), time.Now().UTC(), strictStock(ctx)); err != nil {
+		return before, before, err
+	}
+	s.docs[id] = after
+	return before, after, nil
+}
+
+type memorySalidas struct {
+	mu        sync.RWMutex
+	docs      map[string]models.SalidasData
+	inventory *memoryInventory
+}
+
+func (s *memorySalidas) Create(ctx context.Context, salida models.Salidas) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Stock may only go negative when a supervisor overrode the check
+	data := salida.Data()
+	if err := s.inventory.apply(data.InventoryDeltas(), salida.CreatedAt, salida.StockOverrideBy == ""); err != nil {
+		return "", err
+	}
+	id := uuid.New().String()
+	s.docs[id] = data
+	return id, nil
+}
+
+func (s *memorySalidas) Get(ctx context.Context, id string) (models.SalidasData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	salida, ok := s.docs[id]
+	if !ok {
+		return salida, ErrNotFound
+	}
+	return salida, nil
+}
+
+// Returns the IDs of the salidas matching the filter in listing order, ties
+// are broken by ID so that pages are stable. The caller holds the lock.
+func (s *memorySalidas) sortedIDs(filter SalidaFilter) []string {
+	var ids []string
+	for id, salida := range s.docs {
+		if filter.matches(salida) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := s.docs[ids[i]], s.docs[ids[j]]
+		if filter.less(a, b) || filter.less(b, a) {
+			return filter.less(a, b)
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+func (s *memorySalidas) List(ctx context.Context, filter SalidaFilter) ([]models.SalidasDataWithID, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.sortedIDs(filter)
+	start, end, next, err := pageBounds(ids, filter.PageSize, filter.PageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	var results []models.SalidasDataWithID
+	for _, id := range ids[start:end] {
+		results = append(results, models.SalidasDataWithID{ID: id, SalidasData: s.docs[id]})
+	}
+	return results, next, nil
+}
+
+func (s *memorySalidas) Each(ctx context.Context, filter SalidaFilter, fn func(models.SalidasDataWithID) error) error {
+	filter.PageSize = 0
+	results, _, err := s.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, salida := range results {
+		if err := fn(salida); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memorySalidas) Update(ctx context.Context, id string, patch models.SalidasPatch) (models.SalidasData, models.SalidasData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[id]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	if before.Deleted() {
+		return before, before, ErrDeleted
+	}
+	after := patch.Apply(before)
+	if err := s.inventory.apply(inventoryChange(before.InventoryDeltas(), after.InventoryDeltas()), time.Now().UTC(), strictStock(ctx)); err != nil {
+		return before, before, err
+	}
+	s.docs[id] = after
+	return before, after, nil
+}
+
+func (s *memorySalidas) SoftDelete(ctx context.Context, id string, d models.Deletion) (models.SalidasData, models.SalidasData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[id]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	if before.Deleted() {
+		return before, before, ErrDeleted
+	}
+	after := before.WithDeletion(d)
+	if err := s.inventory.apply(inventoryChange(before.InventoryDeltas(), after.InventoryDeltas()), time.Now().UTC(), strictStock(ctx)); err != nil {
+		return before, before, err
+	}
+	s.docs[id] = after
+	return before, after, nil
+}
+
+func (s *memorySalidas) Restore(ctx context.Context, id, restoredBy string, at time.Time) (models.SalidasData, models.SalidasData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[id]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	if !before.Deleted() {
+		return before, before, ErrNotDeleted
+	}
+	after := before.WithDeletion(models.Deletion{})
+	after.UpdatedBy = restoredBy
+	after.UpdatedAt = at
+	if err := s.inventory.apply(inventoryChange(before.InventoryDeltas(), after.InventoryDeltas()), time.Now().UTC(), strictStock(ctx)); err != nil {
+		return before, before, err
+	}
+	s.docs[id] = after
+	return before, after, nil
+}
+
+type memoryBodegas struct {
+	mu   sync.RWMutex
+	docs map[string]models.Bodega
+}
+
+func (s *memoryBodegas) Create(ctx context.Context, bodega models.Bodega) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.docs[bodega.Code]; ok {
+		return ErrAlreadyExists
+	}
+	s.docs[bodega.Code] = bodega
+	return nil
+}
+
+func (s *memoryBodegas) Get(ctx context.Context, code string) (models.Bodega, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bodega, ok := s.docs[code]
+	if !ok {
+		return bodega, ErrNotFound
+	}
+	return bodega, nil
+}
+
+func (s *memoryBodegas) List(ctx context.Context, includeInactive bool) ([]models.Bodega, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var bodegas []models.Bodega
+	for _, bodega := range s.docs {
+		if bodega.Active || includeInactive {
+			bodegas = append(bodegas, bodega)
+		}
+	}
+	sort.Slice(bodegas, func(i, j int) bool {
+		return bodegas[i].Code < bodegas[j].Code
+	})
+	return bodegas, nil
+}
+
+func (s *memoryBodegas) Update(ctx context.Context, code string, patch models.BodegaPatch) (models.Bodega, models.Bodega, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[code]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	after := patch.Apply(before)
+	s.docs[code] = after
+	return before, after, nil
+}
+
+type memoryProveedores struct {
+	mu   sync.RWMutex
+	docs map[string]models.Proveedor
+}
+
+func (s *memoryProveedores) Create(ctx context.Context, proveedor models.Proveedor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.docs[proveedor.Code]; ok {
+		return ErrAlreadyExists
+	}
+	s.docs[proveedor.Code] = proveedor
+	return nil
+}
+
+func (s *memoryProveedores) Get(ctx context.Context, code string) (models.Proveedor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	proveedor, ok := s.docs[code]
+	if !ok {
+		return proveedor, ErrNotFound
+	}
+	return proveedor, nil
+}
+
+func (s *memoryProveedores) List(ctx context.Context, includeInactive bool) ([]models.Proveedor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var proveedores []models.Proveedor
+	for _, proveedor := range s.docs {
+		if proveedor.Active || includeInactive {
+			proveedores = append(proveedores, proveedor)
+		}
+	}
+	sort.Slice(proveedores, func(i, j int) bool {
+		return proveedores[i].Code < proveedores[j].Code
+	})
+	return proveedores, nil
+}
+
+func (s *memoryProveedores) Update(ctx context.Context, code string, patch models.ProveedorPatch) (models.Proveedor, models.Proveedor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[code]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	after := patch.Apply(before)
+	s.docs[code] = after
+	return before, after, nil
+}
+
+type memoryInventory struct {
+	mu       sync.RWMutex
+	balances map[models.InventoryKey]models.InventoryBalance
+}
+
+// Adds the deltas to the balances. When strict nothing is written if a
+// balance would go negative.
+func (s *memoryInventory) apply(deltas map[models.InventoryKey]int64, at time.Time, strict bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if strict {
+		current := map[models.InventoryKey]int64{}
+		for key := range deltas {
+			current[key] = s.balances[key].Cantidad
+		}
+		if err := checkStock(current, deltas); err != nil {
+			return err
+		}
+	}
+	for key, delta := range deltas {
+		balance := s.balances[key]
+		balance.Cliente, balance.Bodega, balance.SKU = key.Cliente, key.Bodega, key.SKU
+		balance.Cantidad += delta
+		balance.UpdatedAt = at
+		s.balances[key] = balance
+	}
+	return nil
+}
+
+func (s *memoryInventory) List(ctx context.Context, filter InventoryFilter) ([]models.InventoryBalance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var balances []models.InventoryBalance
+	for _, balance := range s.balances {
+		if filter.matches(balance) {
+			balances = append(balances, balance)
+		}
+	}
+	models.SortBalances(balances)
+	return balances, nil
+}
+
+type memorySnapshots struct {
+	mu   sync.RWMutex
+	docs map[string]models.InventorySnapshot
+}
+
+func (s *memorySnapshots) Save(ctx context.Context, snapshot models.InventorySnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot.Balances = append([]models.InventoryBalance(nil), snapshot.Balances...)
+	models.SortBalances(snapshot.Balances)
+	s.docs[snapshot.Period] = snapshot
+	return nil
+}
+
+func (s *memorySnapshots) Get(ctx context.Context, period string, filter InventoryFilter) (models.InventorySnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot, ok := s.docs[period]
+	if !ok {
+		return snapshot, ErrNotFound
+	}
+	var balances []models.InventoryBalance
+	for _, balance := range snapshot.Balances {
+		if filter.matches(balance) {
+			balances = append(balances, balance)
+		}
+	}
+	snapshot.Balances = balances
+	return snapshot, nil
+}
+
+func (s *memorySnapshots) List(ctx context.Context) ([]models.InventorySnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var snapshots []models.InventorySnapshot
+	for _, snapshot := range s.docs {
+		snapshot.Balances = nil
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Period > snapshots[j].Period
+	})
+	return snapshots, nil
+}
+
+type memoryCustomers struct {
+	mu   sync.RWMutex
+	docs map[string]models.Customer
+}
+
+func (s *memoryCustomers) Create(ctx context.Context, id string, customer models.Customer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.docs[id]; ok {
+		return ErrAlreadyExists
+	}
+	customer.ID = id
+	s.docs[id] = customer
+	return nil
+}
+
+func (s *memoryCustomers) Get(ctx context.Context, id string) (models.Customer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	customer, ok := s.docs[id]
+	if !ok {
+		return customer, ErrNotFound
+	}
+	return customer, nil
+}
+
+func (s *memoryCustomers) List(ctx context.Context) ([]models.Customer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var customers []models.Customer
+	for _, customer := range s.docs {
+		customers = append(customers, customer)
+	}
+	sort.Slice(customers, func(i, j int) bool {
+		return customers[i].ID < customers[j].ID
+	})
+	return customers, nil
+}
+
+func (s *memoryCustomers) Replace(ctx context.Context, id string, customer models.Customer) (models.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[id]
+	if !ok {
+		return before, ErrNotFound
+	}
+	customer.ID = id
+	s.docs[id] = customer
+	return before, nil
+}
+
+func (s *memoryCustomers) Update(ctx context.Context, id string, patch models.CustomerPatch) (models.Customer, models.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.docs[id]
+	if !ok {
+		return before, before, ErrNotFound
+	}
+	after := patch.Apply(before)
+	s.docs[id] = after
+	return before, after, nil
+}
+
+type memoryAudit struct {
+	mu      sync.RWMutex
+	entries []models.AuditEntry
+}
+
+func (s *memoryAudit) Append(ctx context.Context, entry models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = uuid.New().String()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *memoryAudit) Query(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []models.AuditEntry
+	// Newest first, like the Firestore query
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		entry := s.entries[i]
+		if filter.Collection != "" && entry.Collection != filter.Collection ||
+			filter.DocumentID != "" && entry.DocumentID != filter.DocumentID ||
+			filter.Actor != "" && entry.Actor != filter.Actor ||
+			!filter.From.IsZero() && entry.Timestamp.Before(filter.From) ||
+			!filter.To.IsZero() && !entry.Timestamp.Before(filter.To) {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
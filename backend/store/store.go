@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+)
+
+var (
+	// ErrNotFound is returned when a document does not exist
+	ErrNotFound = errors.New("document not found")
+	// ErrAlreadyExists is returned when creating a document whose ID is already taken
+	ErrAlreadyExists = errors.New("document already exists")
+	// ErrDeleted is returned when changing a voided movement
+	ErrDeleted = errors.New("document is deleted")
+	// ErrNotDeleted is returned when restoring a movement that is not voided
+	ErrNotDeleted = errors.New("document is not deleted")
+	// ErrInvalidPageToken is returned when a listing is resumed from a malformed or unknown token
+	ErrInvalidPageToken = errors.New("invalid page token")
+	// ErrInsufficientStock is returned when a write takes more stock than a balance
+	// holds, unless its context carries a stock override (see WithStockOverride)
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// Fields entradas and salidas listings can be sorted by
+var (
+	EntradaSortFields = []string{"FechaRecepcion", "Cantidad", "BodegaRecepcion", "Cliente", "ProveedorRecepcion", "TipoDelivery", "CreatedAt"}
+	SalidaSortFields  = []string{"FechaSalida", "BodegaSalida", "Cliente", "ProveedorSalida", "CreatedAt"}
+)
+
+// EntradaFilter narrows an entradas listing, zero values match everything.
+// Voided entradas are skipped unless IncludeDeleted is set.
+type EntradaFilter struct {
+	From               time.Time // FechaRecepcion, inclusive
+	To                 time.Time // FechaRecepcion, exclusive
+	BodegaRecepcion    string
+	Cliente            string
+	ProveedorRecepcion string
+	TipoDelivery       string
+	HasASN             *bool
+	SortBy             string // one of EntradaSortFields, FechaRecepcion by default
+	Descending         bool
+	IncludeDeleted     bool
+	PageSize           int    // 0 lists every match
+	PageToken          string // next page token of the previous page
+}
+
+// SalidaFilter narrows a salidas listing, zero values match everything.
+// Voided salidas are skipped unless IncludeDeleted is set.
+type SalidaFilter struct {
+	From            time.Time // FechaSalida, inclusive
+	To              time.Time // FechaSalida, exclusive
+	BodegaSalida    string
+	Cliente         string
+	ProveedorSalida string
+	SortBy          string // one of SalidaSortFields, FechaSalida by default
+	Descending      bool
+	IncludeDeleted  bool
+	PageSize        int    // 0 lists every match
+	PageToken       string // next page token of the previous page
+}
+
+// EntradaRepository persists documents of the "entradas" collection
+type EntradaRepository interface {
+	Create(ctx context.Context, entrada models.Entradas) (string, error)
+	Get(ctx context.Context, id string) (models.EntradasData, error)
+	// List returns a page of the entradas matching the filter and the token of
+	// the next page, empty on the last one
+	List(ctx context.Context, filter EntradaFilter) ([]models.EntradasDataWithID, string, error)
+	// Each calls fn for every entrada matching the filter, in listing order,
+	// without holding them in memory. It stops at the first error fn returns.
+	Each(ctx context.Context, filter EntradaFilter, fn func(models.EntradasDataWithID) error) error
+	FindByASN(ctx context.Context, asn string) (models.EntradasData, error)
+	// UpdateASN sets the ASN of an entrada and appends the change to its ASN
+	// history in one transaction. It returns the entrada as it was before.
+	// Voided entradas cannot be updated.
+	UpdateASN(ctx context.Context, asn models.ASN, reason string) (models.EntradasData, error)
+	// ASNHistory lists the ASN changes of an entrada, oldest first
+	ASNHistory(ctx context.Context, id string) ([]models.ASNChange, error)
+	// Update applies a patch and returns the entrada before and after it.
+	// Voided entradas cannot be updated.
+	Update(ctx context.Context, id string, patch models.EntradasPatch) (models.EntradasData, models.EntradasData, error)
+	// SoftDelete voids an entrada and returns it before and after
+	SoftDelete(ctx context.Context, id string, d models.Deletion) (models.EntradasData, models.EntradasData, error)
+	// Restore clears the deletion of an entrada and returns it before and after
+	Restore(ctx context.Context, id, restoredBy string, at time.Time) (models.EntradasData, models.EntradasData, error)
+}
+
+// SalidaRepository persists documents of the "salidas" collection
+type SalidaRepository interface {
+	Create(ctx context.Context, salida models.Salidas) (string, error)
+	Get(ctx context.Context, id string) (models.SalidasData, error)
+	// List returns a page of the salidas matching the filter and the token of
+	// the next page, empty on the last one
+	List(ctx context.Context, filter SalidaFilter) ([]models.SalidasDataWithID, string, error)
+	// Each calls fn for every salida matching the filter, in listing order,
+	// without holding them in memory. It stops at the first error fn returns.
+	Each(ctx context.Context, filter SalidaFilter, fn func(models.SalidasDataWithID) error) error
+	// Update applies a patch and returns the salida before and after it.
+	// Voided salidas cannot be updated.
+	Update(ctx context.Context, id string, patch models.SalidasPatch) (models.SalidasData, models.SalidasData, error)
+	// SoftDelete voids a salida and returns it before and after
+	SoftDelete(ctx context.Context, id string, d models.Deletion) (models.SalidasData, models.SalidasData, error)
+	// Restore clears the deletion of a salida and returns it before and after
+	Restore(ctx context.Context, id, restoredBy string, at time.Time) (models.SalidasData, models.SalidasData, error)
+}
+
+// CustomerRepository persists documents of the "customers" collection, keyed by cliente
+type CustomerRepository interface {
+	Create(ctx context.Context, id string, customer models.Customer) error
+	Get(ctx context.Context, id string) (models.Customer, error)
+	// List returns every customer, inactive ones included, ordered by ID
+	List(ctx context.Context) ([]models.Customer, error)
+	// Replace overwrites an existing customer and returns it as it was before
+	Replace(ctx context.Context, id string, customer models.Customer) (models.Customer, error)
+	// Update applies a patch and returns the customer before and after it
+	Update(ctx context.Context, id string, patch models.CustomerPatch) (models.Customer, models.Customer, error)
+}
+
+// BodegaRepository persists documents of the "bodegas" collection, keyed by code
+type BodegaRepository interface {
+	Create(ctx context.Context, bodega models.Bodega) error
+	Get(ctx context.Context, code string) (models.Bodega, error)
+	// List returns the bodegas ordered by code, inactive ones only when includeInactive is set
+	List(ctx context.Context, includeInactive bool) ([]models.Bodega, error)
+	// Update applies a patch and returns the bodega before and after it
+	Update(ctx context.Context, code string, patch models.BodegaPatch) (models.Bodega, models.Bodega, error)
+}
+
+// ProveedorRepository persists documents of the "proveedores" collection, keyed by code
+type ProveedorRepository interface {
+	Create(ctx context.Context, proveedor models.Proveedor) error
+	Get(ctx context.Context, code string) (models.Proveedor, error)
+	// List returns the proveedores ordered by code, inactive ones only when includeInactive is set
+	List(ctx context.Context, includeInactive bool) ([]models.Proveedor, error)
+	// Update applies a patch and returns the proveedor before and after it
+	Update(ctx context.Context, code string, patch models.ProveedorPatch) (models.Proveedor, models.Proveedor, error)
+}
+
+// InventoryFilter narrows an inventory query, zero values match everything
+type InventoryFilter struct {
+	Cliente string
+	Bodega  string
+	SKU     string
+}
+
+// InventoryRepository reads the "inventory" collection. Balances are only
+// written by the entradas and salidas repositories, in the same transaction
+// as the movement that changes them.
+type InventoryRepository interface {
+	List(ctx context.Context, filter InventoryFilter) ([]models.InventoryBalance, error)
+}
+
+// SnapshotRepository persists the monthly inventory closes of the
+// "inventory_snapshots" collection, keyed by period (YYYY-MM)
+type SnapshotRepository interface {
+	// Save writes a snapshot with its balances, replacing a previous close of the same period
+	Save(ctx context.Context, snapshot models.InventorySnapshot) error
+	// Get returns a snapshot with its balances matching the filter
+	Get(ctx context.Context, period string, filter InventoryFilter) (models.InventorySnapshot, error)
+	// List returns the snapshots without their balances, newest first
+	List(ctx context.Context) ([]models.InventorySnapshot, error)
+}
+
+// AuditFilter narrows an audit log query, zero values match everything
+type AuditFilter struct {
+	Collection string
+	DocumentID string
+	Actor      string
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+// AuditRepository appends to and reads the "audit_log" collection.
+// Entries are never updated or deleted.
+type AuditRepository interface {
+	Append(ctx context.Context, entry models.AuditEntry) error
+	Query(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error)
+}
+
+// Store groups the repositories used by the handlers
+type Store struct {
+	Entradas    EntradaRepository
+	Salidas     SalidaRepository
+	Customers   CustomerRepository
+	Bodegas     BodegaRepository
+	Proveedores ProveedorRepository
+	Inventory   InventoryRepository
+	Snapshots   SnapshotRepository
+	Audit       AuditRepository
+
+	close func() error
+}
+
+// Close releases the resources held by the backend, if any
+func (s *Store) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+const gcsPublicPrefix = "https://storage.googleapis.com/"
+
+// ParseGCSURL splits a public GCS URL, as returned by UploadImageToGCS, into
+// its bucket and object
+func ParseGCSURL(url string) (bucket, object string, err error) {
+	path, ok := strings.CutPrefix(url, gcsPublicPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("not a GCS URL: %q", url)
+	}
+	bucket, object, ok = strings.Cut(path, "/")
+	if !ok || bucket == "" || object == "" {
+		return "", "", fmt.Errorf("not a GCS URL: %q", url)
+	}
+	return bucket, object, nil
+}
+
+// DownloadFromGCS reads the object behind a public GCS URL
+func DownloadFromGCS(ctx context.Context, client *storage.Client, url string) ([]byte, error) {
+	bucket, object, err := ParseGCSURL(url)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", url, err)
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// UploadPDFToGCS writes a generated PDF to GCS and returns its public URL
+func UploadPDFToGCS(ctx context.Context, client *storage.Client, bucket, object string, data []byte, uploadSource string) (string, error) {
+	wc := client.Bucket(bucket).Object(object).NewWriter(ctx)
+	wc.ContentType = "application/pdf"
+	wc.Metadata = map[string]string{
+		"upload-source": uploadSource,
+	}
+	if _, err := wc.Write(data); err != nil {
+		return "", fmt.Errorf("failed to write to GCS: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return "", fmt.Errorf("failed to close GCS writer: %w", err)
+	}
+
+	return gcsPublicPrefix + bucket + "/" + object, nil
+}
@@ -0,0 +1,425 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/clopezbyte/app-entradas-salidas/models"
+	"github.com/clopezbyte/app-entradas-salidas/store"
+	"github.com/mailersend/mailersend-go"
+)
+
+// A notification email in one language
+type emailTemplate struct {
+	subject string
+	body    string
+}
+
+const emailHeader = `
+<html>
+  <body style="font-family: Arial, sans-serif; font-size: 14px; color: #333;">`
+
+const emailFooterES = `
+    <p>Saludos,<br>Buho Logistics</p>
+
+    <p style="font-size: 12px; color: #888;"><em>(Correo automático, favor de no responder.)</em></p>
+  </body>
+</html>
+`
+
+const emailFooterEN = `
+    <p>Regards,<br>Buho Logistics</p>
+
+    <p style="font-size: 12px; color: #888;"><em>(Automated email, please do not reply.)</em></p>
+  </body>
+</html>
+`
+
+// Notification templates per event and language
+var emailTemplates = map[string]map[string]emailTemplate{
+	models.EventRMAReceived: {
+		models.LanguageES: {
+			subject: "Nueva devolución de mercancía",
+			body: emailHeader + `
+    <p>Hola,</p>
+
+    <p>Se ha registrado una nueva devolución para el cliente "<strong>{{.Cliente}}</strong>".</p>
+
+    <table cellpadding="5" cellspacing="0" style="border-collapse: collapse;">
+      <tr>
+        <td><strong>Fecha de entrada:</strong></td>
+        <td>{{date .FechaRecepcion}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Bodega:</strong></td>
+        <td>{{.BodegaRecepcion}}</td>
+      </tr>
+      <tr>
+        <td><strong>Cantidad:</strong></td>
+        <td>{{.Cantidad}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Número de remisión:</strong></td>
+        <td>{{.NumeroRemision}}</td>
+      </tr>
+      <tr>
+        <td><strong>Con proveedor:</strong></td>
+        <td>{{.ProveedorRecepcion}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Link a evidencia de entrada:</strong></td>
+        <td><a href="{{.EvidenciaRecepcion}}" target="_blank" rel="noopener noreferrer">{{.EvidenciaRecepcion}}</a></td>
+      </tr>
+    </table>
+` + emailFooterES,
+		},
+		models.LanguageEN: {
+			subject: "New merchandise return",
+			body: emailHeader + `
+    <p>Hello,</p>
+
+    <p>A new return has been received for customer "<strong>{{.Cliente}}</strong>".</p>
+
+    <table cellpadding="5" cellspacing="0" style="border-collapse: collapse;">
+      <tr>
+        <td><strong>Received on:</strong></td>
+        <td>{{date .FechaRecepcion}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Warehouse:</strong></td>
+        <td>{{.BodegaRecepcion}}</td>
+      </tr>
+      <tr>
+        <td><strong>Quantity:</strong></td>
+        <td>{{.Cantidad}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Delivery note number:</strong></td>
+        <td>{{.NumeroRemision}}</td>
+      </tr>
+      <tr>
+        <td><strong>Carrier:</strong></td>
+        <td>{{.ProveedorRecepcion}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Link to the reception evidence:</strong></td>
+        <td><a href="{{.EvidenciaRecepcion}}" target="_blank" rel="noopener noreferrer">{{.EvidenciaRecepcion}}</a></td>
+      </tr>
+    </table>
+` + emailFooterEN,
+		},
+	},
+	models.EventASNAssigned: {
+		models.LanguageES: {
+			subject: "ASN asignado a una entrada",
+			body: emailHeader + `
+    <p>Hola,</p>
+
+    <p>Se asignó el ASN <strong>{{.ASN}}</strong> a una entrada del cliente "<strong>{{.Cliente}}</strong>".</p>
+
+    <table cellpadding="5" cellspacing="0" style="border-collapse: collapse;">
+      <tr>
+        <td><strong>Fecha de ajuste ASN:</strong></td>
+        <td>{{date .FechaAjusteASN}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Fecha de entrada:</strong></td>
+        <td>{{date .FechaRecepcion}}</td>
+      </tr>
+      <tr>
+        <td><strong>Bodega:</strong></td>
+        <td>{{.BodegaRecepcion}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Cantidad:</strong></td>
+        <td>{{.Cantidad}}</td>
+      </tr>
+      <tr>
+        <td><strong>Número de remisión:</strong></td>
+        <td>{{.NumeroRemision}}</td>
+      </tr>
+    </table>
+` + emailFooterES,
+		},
+		models.LanguageEN: {
+			subject: "ASN assigned to a receipt",
+			body: emailHeader + `
+    <p>Hello,</p>
+
+    <p>ASN <strong>{{.ASN}}</strong> was assigned to a receipt of customer "<strong>{{.Cliente}}</strong>".</p>
+
+    <table cellpadding="5" cellspacing="0" style="border-collapse: collapse;">
+      <tr>
+        <td><strong>ASN adjusted on:</strong></td>
+        <td>{{date .FechaAjusteASN}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Received on:</strong></td>
+        <td>{{date .FechaRecepcion}}</td>
+      </tr>
+      <tr>
+        <td><strong>Warehouse:</strong></td>
+        <td>{{.BodegaRecepcion}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Quantity:</strong></td>
+        <td>{{.Cantidad}}</td>
+      </tr>
+      <tr>
+        <td><strong>Delivery note number:</strong></td>
+        <td>{{.NumeroRemision}}</td>
+      </tr>
+    </table>
+` + emailFooterEN,
+		},
+	},
+	models.EventSalidaDispatched: {
+		models.LanguageES: {
+			subject: "Salida de mercancía despachada",
+			body: emailHeader + `
+    <p>Hola,</p>
+
+    <p>Se despachó una salida de mercancía del cliente "<strong>{{.Cliente}}</strong>".</p>
+
+    <table cellpadding="5" cellspacing="0" style="border-collapse: collapse;">
+      <tr>
+        <td><strong>Fecha de salida:</strong></td>
+        <td>{{date .FechaSalida}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Bodega:</strong></td>
+        <td>{{.BodegaSalida}}</td>
+      </tr>
+      <tr>
+        <td><strong>Cantidad:</strong></td>
+        <td>{{.Cantidad}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Número de orden:</strong></td>
+        <td>{{.NumeroOrdenConsecutivo}}</td>
+      </tr>
+      <tr>
+        <td><strong>Recogió:</strong></td>
+        <td>{{.PersonaRecoge}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Link a evidencia de salida:</strong></td>
+        <td><a href="{{.EvidenciaSalida}}" target="_blank" rel="noopener noreferrer">{{.EvidenciaSalida}}</a></td>
+      </tr>
+    </table>
+` + emailFooterES,
+		},
+		models.LanguageEN: {
+			subject: "Merchandise shipment dispatched",
+			body: emailHeader + `
+    <p>Hello,</p>
+
+    <p>A shipment of customer "<strong>{{.Cliente}}</strong>" was dispatched.</p>
+
+    <table cellpadding="5" cellspacing="0" style="border-collapse: collapse;">
+      <tr>
+        <td><strong>Dispatched on:</strong></td>
+        <td>{{date .FechaSalida}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Warehouse:</strong></td>
+        <td>{{.BodegaSalida}}</td>
+      </tr>
+      <tr>
+        <td><strong>Quantity:</strong></td>
+        <td>{{.Cantidad}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Order number:</strong></td>
+        <td>{{.NumeroOrdenConsecutivo}}</td>
+      </tr>
+      <tr>
+        <td><strong>Picked up by:</strong></td>
+        <td>{{.PersonaRecoge}}</td>
+      </tr>
+      <tr style="background-color:#f9f9f9;">
+        <td><strong>Link to the dispatch evidence:</strong></td>
+        <td><a href="{{.EvidenciaSalida}}" target="_blank" rel="noopener noreferrer">{{.EvidenciaSalida}}</a></td>
+      </tr>
+    </table>
+` + emailFooterEN,
+		},
+	},
+}
+
+// Formats the dates of the notifications
+func emailDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// Generates the subject and body of the email of an event, Spanish when the
+// language has no template
+func generateEmail(event, language string, data interface{}) (string, string, error) {
+	templates, ok := emailTemplates[event]
+	if !ok {
+		return "", "", errors.New("no email template for event " + event)
+	}
+	tpl, ok := templates[language]
+	if !ok {
+		tpl = templates[models.LanguageES]
+	}
+
+	// Use html/template for automatic escaping of HTML content
+	t, err := template.New(event).Funcs(template.FuncMap{"date": emailDate}).Parse(tpl.body)
+	if err != nil {
+		return "", "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+
+	return tpl.subject, buf.String(), nil
+}
+
+// Groups the contacts into the emails to send per language. A language without
+// "to" contacts sends to its cc contacts instead, and when it only has bcc
+// contacts each one gets an email of its own so that they stay hidden.
+func groupRecipients(contacts []models.CustomerContact) map[string][]EmailRecipients {
+	byLanguage := map[string]*EmailRecipients{}
+	for _, contact := range contacts {
+		language := contact.Language
+		if language == "" {
+			language = models.LanguageES
+		}
+		recipients, ok := byLanguage[language]
+		if !ok {
+			recipients = &EmailRecipients{}
+			byLanguage[language] = recipients
+		}
+
+		recipient := mailersend.Recipient{Email: contact.Email, Name: contact.Name}
+		switch contact.Delivery {
+		case models.DeliveryCC:
+			recipients.CC = append(recipients.CC, recipient)
+		case models.DeliveryBCC:
+			recipients.BCC = append(recipients.BCC, recipient)
+		default:
+			recipients.To = append(recipients.To, recipient)
+		}
+	}
+
+	emails := map[string][]EmailRecipients{}
+	for language, recipients := range byLanguage {
+		switch {
+		case len(recipients.To) > 0:
+			emails[language] = []EmailRecipients{*recipients}
+		case len(recipients.CC) > 0:
+			emails[language] = []EmailRecipients{{To: recipients.CC, BCC: recipients.BCC}}
+		default:
+			for _, recipient := range recipients.BCC {
+				emails[language] = append(emails[language], EmailRecipients{To: []mailersend.Recipient{recipient}})
+			}
+		}
+	}
+	return emails
+}
+
+// Sends the notification of an event to the contacts of the customer subscribed to it
+func notifyCustomer(ctx context.Context, customers store.CustomerRepository, cliente, event string, data interface{}, attachments []EmailAttachment) {
+	log.Printf("Looking up customer with ID: %s", cliente)
+
+	// Fetch customer document by ID
+	customer, err := customers.Get(ctx, cliente)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// Document not found error
+			log.Printf("No customer found with ID: %s", cliente)
+			return
+		}
+		// Other errors
+		log.Printf("Firestore error: %v", err)
+		return
+	}
+	if !customer.Active() {
+		log.Printf("Customer %s is inactive, notification not sent", cliente)
+		return
+	}
+
+	contacts := customer.Recipients(event)
+	if len(contacts) == 0 {
+		log.Printf("No contacts of customer %s subscribed to %s", cliente, event)
+		return
+	}
+
+	emails := groupRecipients(contacts)
+	languages := make([]string, 0, len(emails))
+	for language := range emails {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	for _, language := range languages {
+		subject, body, err := generateEmail(event, language, data)
+		if err != nil {
+			log.Printf("Email body generation failed: %v", err)
+			continue
+		}
+		for _, recipients := range emails[language] {
+			if err := sendEmail(recipients, subject, body, attachments); err != nil {
+				log.Printf("Failed to send email: %v", err)
+			}
+		}
+	}
+}
+
+// HandleClientEmailNotification notifies the customer of a returned (RMA) entrada
+func HandleClientEmailNotification(ctx context.Context, customers store.CustomerRepository, entrada models.Entradas, attachments ...EmailAttachment) {
+	notifyCustomer(ctx, customers, entrada.Cliente, models.EventRMAReceived, models.EmailData{
+		BodegaRecepcion:    entrada.BodegaRecepcion,
+		Cantidad:           int(entrada.Cantidad),
+		Comentarios:        entrada.Comentarios,
+		EvidenciaRecepcion: entrada.EvidenciaRecepcion,
+		FechaRecepcion:     entrada.FechaRecepcion,
+		NumeroRemision:     entrada.NumeroRemisionFactura,
+		PersonaRecepcion:   entrada.PersonaRecepcion,
+		ProveedorRecepcion: entrada.ProveedorRecepcion,
+		Cliente:            entrada.Cliente,
+		TipoDelivery:       entrada.TipoDelivery,
+	}, attachments)
+}
+
+// HandleASNNotification notifies the customer that an ASN was assigned to an entrada
+func HandleASNNotification(ctx context.Context, customers store.CustomerRepository, entrada models.EntradasData) {
+	notifyCustomer(ctx, customers, entrada.Cliente, models.EventASNAssigned, models.EmailData{
+		BodegaRecepcion:    entrada.BodegaRecepcion,
+		Cantidad:           entrada.Cantidad,
+		Comentarios:        entrada.Comentarios,
+		EvidenciaRecepcion: entrada.EvidenciaRecepcion,
+		FechaRecepcion:     entrada.FechaRecepcion,
+		NumeroRemision:     entrada.NumeroRemision,
+		PersonaRecepcion:   entrada.PersonaRecepcion,
+		ProveedorRecepcion: entrada.ProveedorRecepcion,
+		Cliente:            entrada.Cliente,
+		TipoDelivery:       entrada.TipoDelivery,
+		ASN:                entrada.ASN,
+		FechaAjusteASN:     entrada.FechaAjusteASN,
+	}, nil)
+}
+
+// HandleSalidaNotification notifies the customer that a salida was dispatched
+func HandleSalidaNotification(ctx context.Context, customers store.CustomerRepository, salida models.Salidas, attachments ...EmailAttachment) {
+	notifyCustomer(ctx, customers, salida.Cliente, models.EventSalidaDispatched, models.SalidaEmailData{
+		Cliente:                salida.Cliente,
+		BodegaSalida:           salida.BodegaSalida,
+		Cantidad:               int(salida.Cantidad),
+		FechaSalida:            salida.FechaSalida,
+		NumeroOrdenConsecutivo: salida.NumeroOrdenConsecutivo,
+		PersonaRecoge:          salida.PersonaRecoge,
+		EvidenciaSalida:        salida.EvidenciaSalida,
+	}, attachments)
+}
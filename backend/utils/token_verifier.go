@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// TokenVerifier verifies a bearer ID token and returns its claims.
+// Every implementation reports bad tokens with ErrInvalidToken.
+type TokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error)
+}
+
+// ErrInvalidToken is returned for malformed, expired or unknown tokens
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// FirebaseVerifier verifies ID tokens issued by Firebase Authentication
+type FirebaseVerifier struct {
+	Client *auth.Client
+}
+
+func (v *FirebaseVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	if v.Client == nil {
+		return nil, errors.New("firebase auth client not initialized")
+	}
+
+	// Verify the ID token
+	token, err := v.Client.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// JWTVerifier verifies locally signed HS256 or RS256 tokens, so the API can
+// run without network access to Google. Tokens need the "exp" and "iat"
+// claims. The "sub" claim becomes the UID and every claim, including
+// "role"/"roles", is kept as a custom claim.
+type JWTVerifier struct {
+	Secret    []byte         // HS256 key
+	PublicKey *rsa.PublicKey // RS256 key
+	Issuer    string         // checked when not empty
+	Audience  string         // checked when not empty
+}
+
+// NewJWTVerifierFromEnv builds a JWTVerifier from the environment.
+// The key is read from AUTH_JWT_SECRET or AUTH_JWT_SECRET_FILE (HS256), or from
+// AUTH_JWT_PUBLIC_KEY or AUTH_JWT_PUBLIC_KEY_FILE holding a PEM key (RS256).
+// AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE are optional.
+func NewJWTVerifierFromEnv() (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		Issuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		Audience: os.Getenv("AUTH_JWT_AUDIENCE"),
+	}
+
+	secret, err := envOrFile("AUTH_JWT_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := envOrFile("AUTH_JWT_PUBLIC_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(publicKey) > 0:
+		v.PublicKey, err = jwt.ParseRSAPublicKeyFromPEM(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_JWT_PUBLIC_KEY: %w", err)
+		}
+	case len(secret) > 0:
+		v.Secret = secret
+	default:
+		return nil, errors.New("AUTH_JWT_SECRET or AUTH_JWT_PUBLIC_KEY not set in environment")
+	}
+
+	return v, nil
+}
+
+// Reads the value of name, or the contents of the file named by name_FILE
+func envOrFile(name string) ([]byte, error) {
+	if val := os.Getenv(name); val != "" {
+		return []byte(val), nil
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s_FILE: %w", name, err)
+	}
+	return []byte(strings.TrimSpace(string(data))), nil
+}
+
+func (v *JWTVerifier) keyFunc(t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.Secret != nil {
+			return v.Secret, nil
+		}
+	case *jwt.SigningMethodRSA:
+		if v.PublicKey != nil {
+			return v.PublicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+}
+
+func (v *JWTVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(idToken, claims, v.keyFunc); err != nil {
+		return nil, ErrInvalidToken
+	}
+	// MapClaims only checks the time claims that are present, a token must
+	// expire and say when it was issued. nbf stays optional.
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuedAt(now, true) || !claims.VerifyNotBefore(now, false) {
+		return nil, ErrInvalidToken
+	}
+	if v.Issuer != "" && !claims.VerifyIssuer(v.Issuer, true) {
+		return nil, ErrInvalidToken
+	}
+	if v.Audience != "" && !claims.VerifyAudience(v.Audience, true) {
+		return nil, ErrInvalidToken
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, ErrInvalidToken
+	}
+
+	token := &auth.Token{
+		Subject: sub,
+		UID:     sub,
+		Claims:  map[string]interface{}(claims),
+	}
+	token.Issuer, _ = claims["iss"].(string)
+	token.Audience, _ = claims["aud"].(string)
+	if exp, ok := claims["exp"].(float64); ok {
+		token.Expires = int64(exp)
+	}
+	if iat, ok := claims["iat"].(float64); ok {
+		token.IssuedAt = int64(iat)
+	}
+
+	return token, nil
+}
+
+// StaticVerifier accepts a fixed set of tokens, meant for tests and demos
+type StaticVerifier struct {
+	Tokens map[string]*auth.Token
+}
+
+// NewStaticVerifierFromEnv reads AUTH_STATIC_TOKENS, a JSON object mapping each
+// accepted token to its user, e.g. {"dev-admin": {"uid": "u1", "claims": {"role": "admin"}}}
+func NewStaticVerifierFromEnv() (*StaticVerifier, error) {
+	raw := os.Getenv("AUTH_STATIC_TOKENS")
+	if raw == "" {
+		return nil, errors.New("AUTH_STATIC_TOKENS not set in environment")
+	}
+
+	var users map[string]struct {
+		UID    string                 `json:"uid"`
+		Claims map[string]interface{} `json:"claims"`
+	}
+	if err := json.Unmarshal([]byte(raw), &users); err != nil {
+		return nil, fmt.Errorf("invalid AUTH_STATIC_TOKENS: %w", err)
+	}
+
+	v := &StaticVerifier{Tokens: map[string]*auth.Token{}}
+	for idToken, user := range users {
+		v.Tokens[idToken] = &auth.Token{UID: user.UID, Subject: user.UID, Claims: user.Claims}
+	}
+	return v, nil
+}
+
+func (v *StaticVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	token, ok := v.Tokens[idToken]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return token, nil
+}
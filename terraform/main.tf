@@ -134,6 +134,23 @@ resource "google_cloud_run_v2_job" "in-out-analytics-dbt-job" {
   }
 }
 
+# Cloud Run Job for the monthly inventory close, runs the API image with the inventoryclose command
+resource "google_cloud_run_v2_job" "in-out-inventory-close" {
+  name = "in-out-inventory-close"
+  location = var.region
+  project = var.project_id
+  deletion_protection = "false"
+
+  template {
+    template {
+      containers {
+        image   = "gcr.io/${var.project_id}/in-out-goods-app-api:prodv7"
+        command = ["/inventoryclose"]
+      }
+    }
+  }
+}
+
 #Cloud Composer
 provider "google-beta" {
   alias   = "composer"